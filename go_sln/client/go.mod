@@ -1,3 +1,7 @@
 module sln/client
 
 go 1.25
+
+require sln/ft12 v0.0.0
+
+replace sln/ft12 => ../ft12
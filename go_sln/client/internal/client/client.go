@@ -1,43 +1,96 @@
 package client
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"sln/client/internal/config"
-	"sln/client/internal/frame"
 	"sln/client/internal/util"
+	"sln/ft12"
+	"sln/ft12/capture"
+	"sln/ft12/regmap"
+	"sln/ft12/transport"
 	"sync"
 	"time"
 )
 
+// Коды команд прикладного уровня
+const (
+	cmdReadTime    = 0x01
+	cmdReadArchive = 0x02
+	cmdWriteTime   = 0x03
+	cmdReadParam   = 0x04
+	cmdWriteParam  = 0x05
+)
+
 // Client отвечает за подключение к эмулятору и периодический опрос времени
 type Client struct {
 	cfg    *config.Config
 	logger *log.Logger
+	codec  ft12.Codec
+	tc     ft12.TimeCodec
+	tr     transport.Transport // канал связи с прибором (-transport)
 
-	mu       sync.Mutex
-	conn     net.Conn
-	stopCh   chan struct{}
-	running  bool
-	lastSec  int
-	wg       sync.WaitGroup
-	dialLock sync.Mutex
+	mu        sync.Mutex
+	conn      net.Conn
+	dec       *ft12.Decoder // декодер текущего соединения, хранит хвост между кадрами
+	txLock    sync.Mutex    // одна транзакция запрос/ответ на линии за раз
+	stopCh    chan struct{}
+	running   bool
+	linkReady bool // сброс канала подтверждён на текущем соединении: FCB согласован с прибором
+	fcb       bool // FCB последнего нового запроса; повторы отправляются с тем же значением
+	lastSec   int
+	wg        sync.WaitGroup
+	dialLock  sync.Mutex
+	rec       *capture.Recorder   // запись обмена (-record), nil - не пишем
+	pcap      *capture.PcapWriter // запись обмена в .pcap (-pcap), nil - не пишем
 }
 
 // NewClient создаёт новый клиент с конфигом и логгером
 func NewClient(cfg *config.Config, logger *log.Logger) *Client {
+	// Формат времени и транспорт проверены при старте (main)
+	tc, _ := ft12.LookupTimeCodec(cfg.TimeFormat)
+	tr, _ := NewTransport(cfg)
 	return &Client{
 		cfg:     cfg,
 		logger:  logger,
+		tc:      tc,
+		tr:      tr,
+		codec:   newCodec(cfg),
 		stopCh:  make(chan struct{}),
 		lastSec: -1,
 	}
 }
 
-// Start пытается подключиться и запускает цикл опроса (в фоне).
+// newCodec - кодек кадров по конфигурации (-dialect, -crc, -strict)
+func newCodec(cfg *config.Config) ft12.Codec {
+	return ft12.Codec{
+		Dialect:  ft12.Dialect(cfg.Dialect),
+		Checksum: ft12.ChecksumKind(cfg.CRCMode),
+		Strict:   cfg.StrictCRC,
+	}
+}
+
+// Start открывает файлы записи (-record, -pcap), пытается подключиться и запускает цикл опроса (в фоне).
 func (c *Client) Start() error {
+	if c.cfg.Record != "" {
+		rec, err := capture.Create(c.cfg.Record, capture.SideClient)
+		if err != nil {
+			return fmt.Errorf("record: %w", err)
+		}
+		c.rec = rec
+		c.logger.Printf("recording traffic to %s", c.cfg.Record)
+	}
+	if c.cfg.Pcap != "" {
+		pw, err := capture.CreatePcap(c.cfg.Pcap, capture.SideClient)
+		if err != nil {
+			return fmt.Errorf("pcap: %w", err)
+		}
+		c.pcap = pw
+		c.logger.Printf("writing pcap to %s", c.cfg.Pcap)
+	}
 	_ = c.reconnect()
 
 	c.running = true
@@ -60,26 +113,12 @@ func (c *Client) Stop() {
 	}
 	c.mu.Unlock()
 	c.wg.Wait()
-}
-
-// connect устанавливает TCP-соединение к серверу
-func (c *Client) connect() error {
-	c.dialLock.Lock()
-	defer c.dialLock.Unlock()
-
-	addr := fmt.Sprintf("%s:%d", c.cfg.Host, c.cfg.Port)
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		return err
+	if c.rec != nil {
+		_ = c.rec.Close()
 	}
-	c.mu.Lock()
-	if c.conn != nil {
-		_ = c.conn.Close()
+	if c.pcap != nil {
+		_ = c.pcap.Close()
 	}
-	c.conn = conn
-	c.mu.Unlock()
-	c.logger.Printf("connected to %s", addr)
-	return nil
 }
 
 // pollLoop - основной цикл, тикер каждую секунду; запускает опрос на секундах кратных 5
@@ -111,69 +150,495 @@ func (c *Client) pollLoop() {
 
 // performPoll формирует запрос, отправляет и обрабатывает ответ с retry/timeout
 func (c *Client) performPoll() {
+	c.txLock.Lock()
+	defer c.txLock.Unlock()
+
 	if err := c.ensureConn(); err != nil {
 		c.logger.Printf("cannot connect: %v", err)
 		return
 	}
+	if err := c.ensureLink(); err != nil {
+		c.logger.Printf("link reset failed: %v", err)
+		return
+	}
 
-	control := byte(0x00)
-	addr := byte(c.cfg.AdapterAddr & 0xFF)
-	data := []byte{0x01} // команда чтения времени
+	respFrame, err := c.request(func() []byte { return []byte{cmdReadTime} })
+	if err != nil {
+		c.logger.Printf("%v", err)
+		return
+	}
+	if ctrl := ft12.ParseControl(respFrame.Control); respFrame.Kind == ft12.KindShort && ctrl.IsNack() {
+		c.logger.Printf("device NACK to read-time: %s", ctrl.FuncName())
+		return
+	}
+	if err := deviceError(respFrame); err != nil {
+		c.logger.Printf("read-time failed: %v", err)
+		return
+	}
+	if respFrame.Kind != ft12.KindVariable {
+		c.logger.Printf("unexpected %s frame in response to read-time", respFrame.Kind)
+		return
+	}
+	payload := respFrame.Data
+	if len(payload) == 0 {
+		c.logger.Printf("empty payload")
+		return
+	}
+	if payload[0] != cmdReadTime {
+		c.logger.Printf("unexpected cmd in payload: 0x%02X", payload[0])
+		return
+	}
+	dt, err := c.tc.Decode(payload[1:])
+	if err != nil {
+		// Если разбор не удаётся - логируем сырые байты
+		c.logger.Printf("time decode failed (%s): %v", c.cfg.TimeFormat, err)
+		c.logger.Printf("device time (raw): % X", payload[1:])
+		return
+	}
+	var flags string
+	if dt.Invalid {
+		flags += " invalid"
+	}
+	if dt.Summer {
+		flags += " summer"
+	}
+	drift := dt.Time.Sub(time.Now())
+	c.logger.Printf("device time: %s%s (drift %s)", dt.Time.Format("2006-01-02T15:04:05.000Z07:00"), flags, drift.Round(time.Millisecond))
 
-	req := frame.BuildSkeleton(control, addr, data)
-	req = frame.AppendChecksum(req, c.cfg.CRCMode)
+	// Политика синхронизации: переводим часы, если расхождение больше -syncdrift
+	if c.cfg.SyncDrift > 0 && (drift > time.Duration(c.cfg.SyncDrift)*time.Second || -drift > time.Duration(c.cfg.SyncDrift)*time.Second) {
+		c.logger.Printf("drift exceeds %ds, setting device clock", c.cfg.SyncDrift)
+		if err := c.writeTime(time.Now()); err != nil {
+			c.logger.Printf("set time failed: %v", err)
+		}
+	}
+}
+
+// SetTime устанавливает часы устройства (команда 0x03) и ждёт подтверждения
+func (c *Client) SetTime(t time.Time) error {
+	c.txLock.Lock()
+	defer c.txLock.Unlock()
+
+	if err := c.ensureConn(); err != nil {
+		return err
+	}
+	if err := c.ensureLink(); err != nil {
+		return fmt.Errorf("link reset failed: %w", err)
+	}
+	return c.writeTime(t)
+}
 
+// writeTime отправляет команду установки времени в формате -timefmt; вызывается под txLock
+func (c *Client) writeTime(t time.Time) error {
+	// Если запрос собирается заново на новом соединении, время сдвигается на прошедшее,
+	// чтобы прибор не получил устаревшую метку
+	start := time.Now()
+	sent := t
+	resp, err := c.request(func() []byte {
+		sent = t.Add(time.Since(start))
+		return append([]byte{cmdWriteTime}, c.tc.Encode(ft12.DeviceTime{Time: sent, Summer: sent.IsDST()})...)
+	})
+	if err != nil {
+		return err
+	}
+	if err := confirm(resp); err != nil {
+		return err
+	}
+	c.logger.Printf("device clock set to %s", sent.Format("2006-01-02T15:04:05.000Z07:00"))
+	return nil
+}
+
+// ReadParam читает параметр прибора (команда 0x04) и разбирает его по описанию из карты регистров
+func (c *Client) ReadParam(reg *regmap.Register) (regmap.Value, error) {
+	c.txLock.Lock()
+	defer c.txLock.Unlock()
+
+	if err := c.ensureConn(); err != nil {
+		return regmap.Value{}, err
+	}
+	if err := c.ensureLink(); err != nil {
+		return regmap.Value{}, fmt.Errorf("link reset failed: %w", err)
+	}
+
+	resp, err := c.request(func() []byte { return []byte{cmdReadParam, byte(reg.Address), byte(reg.Address >> 8)} })
+	if err != nil {
+		return regmap.Value{}, err
+	}
+	if ctrl := ft12.ParseControl(resp.Control); resp.Kind == ft12.KindShort && ctrl.IsNack() {
+		return regmap.Value{}, fmt.Errorf("register %q: device NACK: %s", reg.Name, ctrl.FuncName())
+	}
+	if err := deviceError(resp); err != nil {
+		return regmap.Value{}, fmt.Errorf("register %q: %w", reg.Name, err)
+	}
+	d := resp.Data
+	if resp.Kind != ft12.KindVariable || len(d) < 3 || d[0] != cmdReadParam {
+		return regmap.Value{}, fmt.Errorf("register %q: unexpected response %s % X", reg.Name, resp.Kind, d)
+	}
+	if addr := uint16(d[1]) | uint16(d[2])<<8; addr != reg.Address {
+		return regmap.Value{}, fmt.Errorf("register %q: response for address %d, want %d", reg.Name, addr, reg.Address)
+	}
+	return reg.Decode(d[3:])
+}
+
+// WriteParam записывает параметр прибора (команда 0x05) и ждёт подтверждения
+func (c *Client) WriteParam(reg *regmap.Register, v regmap.Value) error {
+	raw, err := reg.Encode(v)
+	if err != nil {
+		return err
+	}
+	c.txLock.Lock()
+	defer c.txLock.Unlock()
+
+	if err := c.ensureConn(); err != nil {
+		return err
+	}
+	if err := c.ensureLink(); err != nil {
+		return fmt.Errorf("link reset failed: %w", err)
+	}
+
+	data := append([]byte{cmdWriteParam, byte(reg.Address), byte(reg.Address >> 8)}, raw...)
+	resp, err := c.request(func() []byte { return data })
+	if err != nil {
+		return err
+	}
+	if err := confirm(resp); err != nil {
+		return fmt.Errorf("register %q: %w", reg.Name, err)
+	}
+	return nil
+}
+
+// request отправляет новый запрос с DATA = data() и ждёт ответ (см. exchange).
+// data вызывается ещё раз, если запрос пришлось собрать заново на новом соединении
+func (c *Client) request(data func() []byte) (*ft12.Frame, error) {
+	req, err := c.buildRequest(data())
+	if err != nil {
+		return nil, err
+	}
+	return c.exchange(req, func() ([]byte, error) {
+		if err := c.ensureLink(); err != nil {
+			return nil, fmt.Errorf("link reset failed: %w", err)
+		}
+		return c.buildRequest(data())
+	})
+}
+
+// exchange отправляет запрос и ждёт корректный ответный кадр с retry/timeout.
+// После таймаута или битого ответа запрос повторяется по тому же соединению теми же байтами,
+// т.е. с тем же FCB: если потерялся ответ, прибор узнает повтор (после сброса канала) и вернёт
+// сохранённый ответ, не выполняя команду ещё раз. Если соединение потеряно (EOF, ошибка записи), клиент
+// переподключается и отправляет запрос, собранный rebuild заново: со сбросом канала
+// (-linkreset) и новым FCB. rebuild == nil - повторить те же байты (сам сброс канала)
+func (c *Client) exchange(req []byte, rebuild func() ([]byte, error)) (*ft12.Frame, error) {
 	c.logger.Printf("TX request: %s", util.HexDump(req))
+	c.dropStale()
 
 	var lastErr error
+	lost := false        // соединение потеряно, повтор - только после переподключения
+	var sent []time.Time // когда отправлены копии запроса на этом соединении, ответ на которые ещё не прочитан
+	var latency time.Duration
 	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
-		if attempt > 0 {
-			c.logger.Printf("retry attempt %d", attempt)
+		if attempt > 0 && !lost {
+			c.logger.Printf("retry attempt %d (same FCB)", attempt)
+			c.dropStale()
+		}
+		if lost {
+			select {
+			case <-c.stopCh:
+				return nil, fmt.Errorf("client stopped: last error: %v", lastErr)
+			case <-time.After(200 * time.Millisecond):
+			}
+			c.logger.Printf("retry attempt %d (new connection)", attempt)
+			if err := c.ensureConn(); err != nil {
+				lastErr = err
+				continue
+			}
+			lost = false
+			sent = nil
+			if rebuild != nil {
+				r, err := rebuild()
+				if err != nil {
+					return nil, err
+				}
+				req = r
+				c.logger.Printf("TX request: %s", util.HexDump(req))
+			}
 		}
 		if err := c.write(req); err != nil {
 			lastErr = err
 			c.logger.Printf("write error: %v", err)
-			_ = c.reconnect()
-			time.Sleep(200 * time.Millisecond)
+			c.disconnect()
+			lost = true
 			continue
 		}
+		sent = append(sent, time.Now())
 		resp, err := c.readFrameWithTimeout(time.Duration(c.cfg.TimeoutMs) * time.Millisecond)
 		if err != nil {
 			lastErr = err
 			c.logger.Printf("read error: %v", err)
-			_ = c.reconnect()
-			time.Sleep(200 * time.Millisecond)
+			if !isTimeout(err) {
+				c.disconnect()
+				lost = true
+			}
 			continue
 		}
+		// Ответы приходят по порядку: этот - на самую раннюю копию без ответа
+		latency = time.Since(sent[0])
+		sent = sent[1:]
 		c.logger.Printf("RX response: %s", util.HexDump(resp))
 
 		// Проверка контрольной суммы/структуры фрейма
-		if err := frame.VerifyFrame(resp); err != nil {
+		respFrame, err := c.codec.Decode(resp)
+		if err != nil {
 			lastErr = err
 			c.logger.Printf("frame verification failed: %v", err)
+			var csErr *ft12.ChecksumError
+			if errors.As(err, &csErr) && csErr.Detected != "" {
+				c.logger.Printf("server appears to use %s (configured %s)", csErr.Detected, csErr.Want)
+			}
 			continue
 		}
-		payload := frame.PayloadData(resp)
-		if len(payload) == 0 {
-			c.logger.Printf("empty payload")
-			return
+		c.logger.Printf("RX frame: %s", ft12.Describe(respFrame))
+		if err := c.checkAddress(respFrame); err != nil {
+			lastErr = err
+			c.logger.Printf("%v", err)
+			continue
+		}
+		if respFrame.Kind == ft12.KindVariable && respFrame.ChecksumKind != c.codec.Checksum {
+			c.logger.Printf("server appears to use %s (configured %s)", respFrame.ChecksumKind, c.codec.Checksum)
+		}
+		c.skipLate(sent, latency)
+		return respFrame, nil
+	}
+	return nil, fmt.Errorf("all retries failed: last error: %v", lastErr)
+}
+
+// skipLate вычитывает запоздавшие ответы на копии повторённого запроса, отправленные в sent:
+// прибор отвечает на каждую, и ответ на копию нельзя принять за ответ на следующий запрос.
+// Прибор обрабатывает запросы по одному, поэтому ответ на копию ждём с той же задержкой latency,
+// что у полученного ответа, от момента отправки копии или предыдущего ответа (что позже)
+func (c *Client) skipLate(sent []time.Time, latency time.Duration) {
+	prev := time.Now()
+	for _, t := range sent {
+		if t.After(prev) {
+			prev = t
 		}
-		if payload[0] != 0x01 {
-			c.logger.Printf("unexpected cmd in payload: 0x%02X", payload[0])
+		raw, err := c.readFrameWithTimeout(time.Until(prev.Add(latency)) + 200*time.Millisecond)
+		if err != nil {
 			return
 		}
-		timeStr := string(payload[1:])
-		ts, err := time.Parse("2006-01-02 15:04:05", timeStr)
+		prev = time.Now()
+		c.logger.Printf("late response to repeated request dropped: %s", util.HexDump(raw))
+	}
+}
+
+// ReadArchive читает n байт архива устройства (команда 0x02).
+// Ответ приходит несколькими кадрами; каждый проверяется по контрольной сумме
+// и добавляется в сборку по заголовку сегмента (FIR/FIN/seq)
+func (c *Client) ReadArchive(n int) ([]byte, error) {
+	if n < 0 || n > 0xFFFF {
+		return nil, fmt.Errorf("archive length %d out of range 0..65535", n)
+	}
+	c.txLock.Lock()
+	defer c.txLock.Unlock()
+
+	if err := c.ensureConn(); err != nil {
+		return nil, err
+	}
+	if err := c.ensureLink(); err != nil {
+		return nil, fmt.Errorf("link reset failed: %w", err)
+	}
+
+	// Ошибка в любом сегменте - передача повторяется целиком как новый запрос (новый FCB):
+	// чтение архива безопасно выполнить ещё раз, а повтор с тем же FCB вернул бы прерванный
+	// ответ. Если соединение потеряно, перед повтором - переподключение и сброс канала
+	var lastErr error
+	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			c.logger.Printf("retry attempt %d (new transfer)", attempt)
+			if err := c.ensureConn(); err != nil {
+				lastErr = err
+				time.Sleep(200 * time.Millisecond)
+				continue
+			}
+			if err := c.ensureLink(); err != nil {
+				return nil, fmt.Errorf("link reset failed: %w", err)
+			}
+			c.drain()
+		}
+		req, err := c.buildRequest([]byte{cmdReadArchive, byte(n), byte(n >> 8)})
 		if err != nil {
-			// Если парсинг не удаётся - логируем raw строку
-			c.logger.Printf("time parse failed, raw='%s'", timeStr)
-			c.logger.Printf("device time (raw): %s", timeStr)
-		} else {
-			c.logger.Printf("device time: %s", ts.Format(time.RFC3339))
+			return nil, err
 		}
-		return
+		data, err := c.readSegmented(req, cmdReadArchive)
+		if err == nil {
+			return data, nil
+		}
+		// Отказ прибора повтором не исправить
+		var devErr *ft12.DeviceError
+		if errors.As(err, &devErr) {
+			return nil, err
+		}
+		lastErr = err
+		c.logger.Printf("archive transfer failed: %v", err)
 	}
-	c.logger.Printf("all retries failed: last error: %v", lastErr)
+	return nil, fmt.Errorf("all retries failed: last error: %v", lastErr)
+}
+
+// readSegmented отправляет запрос и собирает многокадровый ответ на команду cmd
+func (c *Client) readSegmented(req []byte, cmd byte) ([]byte, error) {
+	c.logger.Printf("TX request: %s", util.HexDump(req))
+	c.dropStale()
+	if err := c.write(req); err != nil {
+		c.disconnect()
+		return nil, err
+	}
+
+	var ra ft12.Reassembler
+	for seg := 0; ; seg++ {
+		raw, err := c.readFrameWithTimeout(time.Duration(c.cfg.TimeoutMs) * time.Millisecond)
+		if err != nil {
+			if !isTimeout(err) {
+				c.disconnect()
+			}
+			return nil, fmt.Errorf("segment %d: %w", seg, err)
+		}
+		c.logger.Printf("RX segment: %s", util.HexDump(raw))
+		f, err := c.codec.Decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %w", seg, err)
+		}
+		if err := c.checkAddress(f); err != nil {
+			return nil, fmt.Errorf("segment %d: %w", seg, err)
+		}
+		if err := deviceError(f); err != nil {
+			return nil, err
+		}
+		if f.Kind != ft12.KindVariable || len(f.Data) < 2 || f.Data[0] != cmd {
+			return nil, fmt.Errorf("segment %d: unexpected response %s % X", seg, f.Kind, f.Data)
+		}
+		done, err := ra.Add(f.Data[1:])
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %w", seg, err)
+		}
+		if done {
+			c.logger.Printf("reassembled %d bytes from %d segment(s)", len(ra.Bytes()), seg+1)
+			return ra.Bytes(), nil
+		}
+	}
+}
+
+// drain вычитывает и отбрасывает кадры, пока линия не замолчит (хвост прерванной передачи)
+func (c *Client) drain() {
+	for {
+		if _, err := c.readFrameWithTimeout(200 * time.Millisecond); err != nil {
+			break
+		}
+	}
+	c.dropStale()
+}
+
+// ensureLink при включённом -linkreset сбрасывает канал (короткий кадр FC 0)
+// один раз на каждое новое соединение
+func (c *Client) ensureLink() error {
+	if !c.cfg.LinkReset {
+		return nil
+	}
+	c.mu.Lock()
+	ready := c.linkReady
+	c.mu.Unlock()
+	if ready {
+		return nil
+	}
+
+	// PRM=1, FC=0: сброс удалённого канала
+	ctrl := ft12.ControlField{PRM: true, Func: ft12.FuncResetLink}
+	req := ft12.BuildShort(ctrl.Byte(), byte(c.cfg.AdapterAddr&0xFF))
+	resp, err := c.exchange(req, nil)
+	if err != nil {
+		return err
+	}
+	if err := confirm(resp); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.linkReady = true
+	// После сброса канала вторичная станция ждёт FCB=1
+	c.fcb = false
+	c.mu.Unlock()
+	c.logger.Printf("link reset confirmed (%s)", resp.Kind)
+	return nil
+}
+
+// confirm проверяет, что ответ на команду записи является положительным подтверждением:
+// односимвольный 0xE5 или короткий кадр вторичной станции с FC 0 (ACK).
+// Отрицательный ответ прибора возвращается как *ft12.DeviceError
+func confirm(f *ft12.Frame) error {
+	switch f.Kind {
+	case ft12.KindAck:
+		return nil
+	case ft12.KindVariable:
+		if err := deviceError(f); err != nil {
+			return err
+		}
+	case ft12.KindShort:
+		ctrl := ft12.ParseControl(f.Control)
+		if ctrl.IsConfirm() {
+			return nil
+		}
+		if ctrl.IsNack() {
+			return fmt.Errorf("command rejected by device: %s", ctrl.FuncName())
+		}
+		return fmt.Errorf("unexpected confirmation [%s]", ctrl)
+	}
+	return fmt.Errorf("unexpected %s frame instead of confirmation", f.Kind)
+}
+
+// deviceError возвращает *ft12.DeviceError, если кадр - отрицательный ответ прибора
+// (errors.Is(err, ft12.ErrUnknownCommand) и т.п.)
+func deviceError(f *ft12.Frame) error {
+	if f.Kind != ft12.KindVariable {
+		return nil
+	}
+	if de, ok := ft12.ParseError(f.Data); ok {
+		return de
+	}
+	return nil
+}
+
+// checkAddress отбрасывает ответы не от нашего адаптера (0xE5 адреса не содержит)
+func (c *Client) checkAddress(f *ft12.Frame) error {
+	want := byte(c.cfg.AdapterAddr & 0xFF)
+	if f.Kind != ft12.KindAck && f.Address != want {
+		return fmt.Errorf("response from address 0x%02X, expected 0x%02X", f.Address, want)
+	}
+	return nil
+}
+
+// buildRequest собирает кадр нового запроса к адаптеру с DATA = data (см. nextControl)
+func (c *Client) buildRequest(data []byte) ([]byte, error) {
+	reqFrame := ft12.Frame{
+		Control:      c.nextControl(),
+		Address:      byte(c.cfg.AdapterAddr & 0xFF),
+		Data:         data,
+		ChecksumKind: ft12.ChecksumKind(c.cfg.CRCMode),
+		Dialect:      c.codec.Dialect,
+	}
+	return reqFrame.Encode()
+}
+
+// nextControl формирует CONTROL нового запроса первичной станции (FC 3).
+// После подтверждённого сброса канала (-linkreset) запрос идёт с FCV=1 и FCB переключается:
+// повторы того же запроса отправляются теми же байтами, т.е. с прежним FCB, и эмулятор
+// отвечает на них сохранённым ответом, не выполняя команду ещё раз. Без сброса канала
+// FCB не согласован с прибором, поэтому запрос идёт с FCV=0 и всегда выполняется заново
+func (c *Client) nextControl() byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.linkReady {
+		return ft12.ControlField{PRM: true, Func: ft12.FuncUserData}.Byte()
+	}
+	c.fcb = !c.fcb
+	return ft12.ControlField{PRM: true, FCB: c.fcb, FCV: true, Func: ft12.FuncUserData}.Byte()
 }
 
 // write отправляет байты в текущее соединение (защищено мьютексом).
@@ -195,24 +660,28 @@ func (c *Client) readFrameWithTimeout(timeout time.Duration) ([]byte, error) {
 		return nil, fmt.Errorf("no connection")
 	}
 	conn := c.conn
+	dec := c.dec
 	c.mu.Unlock()
 
-	var buf bytes.Buffer
-	tmp := make([]byte, 1024)
-	deadline := time.Now().Add(timeout)
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	before := dec.Stats()
+	frameBytes, _, err := dec.Next()
+	if st := dec.Stats(); st.Discarded > before.Discarded {
+		c.logger.Printf("decoder dropped %d garbage byte(s): %s", st.Discarded-before.Discarded, st)
+	}
+	return frameBytes, err
+}
 
-	for {
-		_ = conn.SetReadDeadline(deadline)
-		n, err := conn.Read(tmp)
-		if err != nil {
-			return nil, err
-		}
-		if n > 0 {
-			buf.Write(tmp[:n])
-		}
-		if frameBytes, ok := frame.ExtractFrame(&buf); ok {
-			return frameBytes, nil
-		}
+// dropStale отбрасывает непрочитанные байты от предыдущих ответов, чтобы не принять их за ответ на новый запрос
+func (c *Client) dropStale() {
+	c.mu.Lock()
+	dec := c.dec
+	c.mu.Unlock()
+	if dec == nil {
+		return
+	}
+	if n := dec.Reset(); n > 0 {
+		c.logger.Printf("dropped %d stale byte(s) before request", n)
 	}
 }
 
@@ -227,29 +696,57 @@ func (c *Client) ensureConn() error {
 	return c.reconnect()
 }
 
-// reconnect переподключается к серверу (с блокировкой, чтобы не было parallel dial).
+// reconnect переподключается к прибору (с блокировкой, чтобы не было parallel dial).
 func (c *Client) reconnect() error {
+	c.dialLock.Lock()
+	defer c.dialLock.Unlock()
+
 	c.dialLog("reconnecting...")
-	c.mu.Lock()
-	if c.conn != nil {
-		_ = c.conn.Close()
-		c.conn = nil
-	}
-	c.mu.Unlock()
+	c.disconnect()
 
-	addr := fmt.Sprintf("%s:%d", c.cfg.Host, c.cfg.Port)
-	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	conn, err := c.tr.Dial(2 * time.Second)
 	if err != nil {
 		c.dialLog("reconnect failed: %v", err)
 		return err
 	}
+	conn = c.wrap(conn)
 	c.mu.Lock()
 	c.conn = conn
+	c.dec = ft12.NewDecoder(conn, c.codec, 0)
 	c.mu.Unlock()
 	c.dialLog("reconnected")
 	return nil
 }
 
+// disconnect закрывает потерянное соединение; следующий запрос переподключится (ensureConn)
+// и заново сбросит канал (ensureLink)
+func (c *Client) disconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	c.linkReady = false
+}
+
+// isTimeout - истёк таймаут чтения; соединение при этом живо и запрос можно повторить по нему
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || errors.As(err, &ne) && ne.Timeout()
+}
+
+// wrap включает запись обмена для нового соединения, если заданы -record / -pcap
+func (c *Client) wrap(conn net.Conn) net.Conn {
+	if c.rec != nil {
+		conn = c.rec.Wrap(conn)
+	}
+	if c.pcap != nil {
+		conn = c.pcap.Wrap(conn)
+	}
+	return conn
+}
+
 // dialLog - вспомогательный лог для событий подключения
 func (c *Client) dialLog(format string, args ...interface{}) {
 	c.logger.Printf("[dial] "+format, args...)
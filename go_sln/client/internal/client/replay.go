@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"sln/client/internal/config"
+	"sln/ft12/capture"
+	"time"
+)
+
+// Replay воспроизводит запись (-replay) в эмулятор: клиент играет роль опрашивающей стороны.
+// Каждое записанное соединение проигрывается в новом соединении (-transport); отправляются куски
+// опрашивающей стороны, ответы эмулятора сравниваются с записанными ответами прибора
+func Replay(cfg *config.Config, logger *log.Logger) error {
+	capt, err := capture.Load(cfg.Replay)
+	if err != nil {
+		return err
+	}
+	conns := capt.Connections()
+	logger.Printf("replaying %s (recorded by %s): %d connection(s), speed=%g", cfg.Replay, capt.Side, len(conns), cfg.ReplaySpeed)
+
+	r := &capture.Replayer{
+		Speed: cfg.ReplaySpeed,
+		Wait:  time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		Codec: newCodec(cfg),
+		Logf:  logger.Printf,
+	}
+	poller := func(rec capture.Record) bool { return !capt.FromDevice(rec) }
+	tr, err := NewTransport(cfg)
+	if err != nil {
+		return err
+	}
+	for i, recs := range conns {
+		conn, err := tr.Dial(2 * time.Second)
+		if err != nil {
+			return err
+		}
+		logger.Printf("replay connection %d/%d (%s) -> %s", i+1, len(conns), recs[0].Conn, tr)
+		st, err := r.Play(conn, recs, poller)
+		_ = conn.Close()
+		if err != nil {
+			return fmt.Errorf("connection %d: %w", i+1, err)
+		}
+		logger.Printf("replay connection %d done: sent=%d matched=%d differed=%d missing=%d", i+1, st.Sent, st.Matched, st.Differed, st.Missing)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sln/client/internal/config"
+	"sln/ft12/serial"
+	"sln/ft12/transport"
+	"strconv"
+)
+
+// NewTransport создаёт транспорт по конфигурации (-transport, -tls) и проверяет его параметры
+func NewTransport(cfg *config.Config) (transport.Transport, error) {
+	tr, err := newBaseTransport(cfg)
+	if err != nil || !cfg.TLS {
+		return tr, err
+	}
+	inner, ok := tr.(transport.Net)
+	if !ok || inner.Network == transport.KindUDP {
+		return nil, fmt.Errorf("-tls needs tcp or unix transport, not %s", cfg.Transport)
+	}
+	tc, err := newTLSConfig(cfg, inner)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	return transport.TLS{Inner: inner, Config: tc}, nil
+}
+
+func newBaseTransport(cfg *config.Config) (transport.Transport, error) {
+	switch cfg.Transport {
+	case transport.KindTCP, transport.KindUDP:
+		return transport.Net{Network: cfg.Transport, Addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))}, nil
+	case transport.KindUnix:
+		if cfg.Socket == "" {
+			return nil, fmt.Errorf("unix transport needs -socket")
+		}
+		return transport.Net{Network: transport.KindUnix, Addr: cfg.Socket}, nil
+	case transport.KindSerial:
+		if cfg.Device == "" {
+			return nil, fmt.Errorf("serial transport needs -device")
+		}
+		parity, err := serial.ParseParity(cfg.Parity)
+		if err != nil {
+			return nil, err
+		}
+		mode := serial.Mode{Baud: cfg.Baud, DataBits: cfg.DataBits, Parity: parity, StopBits: cfg.StopBits}
+		if err := mode.Validate(); err != nil {
+			return nil, err
+		}
+		return transport.Serial{Device: cfg.Device, Mode: mode}, nil
+	}
+	return nil, fmt.Errorf("unknown transport %q (want tcp | udp | unix | serial)", cfg.Transport)
+}
+
+// newTLSConfig собирает настройки TLS клиента: доверенные CA (-tlsca, по умолчанию системные),
+// сертификат клиента для взаимной аутентификации (-tlscert/-tlskey) и имя сервера (-tlsservername).
+// Имя нужно для SNI и проверки сертификата; по умолчанию это -host
+func newTLSConfig(cfg *config.Config, inner transport.Net) (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSInsecure,
+	}
+	if tc.ServerName == "" && inner.Network == transport.KindTCP {
+		tc.ServerName = cfg.Host
+	}
+	if tc.ServerName == "" && !tc.InsecureSkipVerify {
+		return nil, fmt.Errorf("tls over %s needs -tlsservername", inner.Network)
+	}
+	if cfg.TLSCA != "" {
+		b, err := os.ReadFile(cfg.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no PEM certificates", cfg.TLSCA)
+		}
+		tc.RootCAs = pool
+	}
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		if cfg.TLSCert == "" || cfg.TLSKey == "" {
+			return nil, fmt.Errorf("-tlscert and -tlskey must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
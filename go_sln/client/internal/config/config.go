@@ -1,30 +1,99 @@
 package config
 
-import "flag"
+import (
+	"flag"
+	"strings"
+)
 
 // Config хранит параметры запуска клиента
 type Config struct {
-	Host         string
-	Port         int
-	CRCMode      string
-	AdapterAddr  int
-	TimeoutMs    int
-	Retries      int
-	LogFile      string
-	PollEverySec int
+	Transport     string // tcp | udp | unix | serial
+	Host          string
+	Port          int
+	Socket        string // путь unix-сокета (-transport unix)
+	TLS           bool   // TLS поверх tcp/unix
+	TLSCA         string // доверенные CA (PEM); пусто - системные
+	TLSCert       string // сертификат и ключ клиента для взаимной аутентификации (PEM)
+	TLSKey        string
+	TLSServerName string // имя сервера для SNI и проверки сертификата; пусто - -host
+	TLSInsecure   bool   // не проверять сертификат сервера (только для тестов)
+	Device        string // последовательный порт, например /dev/ttyUSB0
+	Baud          int
+	DataBits      int
+	Parity        string // N | E | O
+	StopBits      int
+	CRCMode       string
+	CRCDefs       stringList // дополнительные алгоритмы CRC для реестра ft12
+	Dialect       string
+	StrictCRC     bool
+	AdapterAddr   int
+	TimeoutMs     int
+	Retries       int
+	LogFile       string
+	PollEverySec  int
+	LinkReset     bool
+	ArchiveLen    int        // сколько байт архива прочитать один раз после старта (0 - не читать)
+	TimeFormat    string     // формат времени устройства (ft12.LookupTimeCodec)
+	SetTime       string     // один раз после старта установить часы устройства: "now" или "YYYY-MM-DD HH:MM:SS" ("" - не устанавливать)
+	SyncDrift     int        // синхронизировать часы, если расхождение больше N секунд (0 - не синхронизировать)
+	RegMap        string     // JSON-файл карты регистров прибора (regmap)
+	ReadParams    string     // имена параметров через запятую для разового чтения после старта
+	WriteParams   stringList // name=value для разовой записи после старта
+	Record        string     // файл записи обмена (capture JSONL)
+	Pcap          string     // файл записи обмена в формате libpcap
+	Replay        string     // воспроизвести запись в эмулятор вместо опроса
+	ReplaySpeed   float64    // темп воспроизведения: 1 - исходный, N - в N раз быстрее, 0 - без пауз
 }
 
 // Load парсит флаги командной строки и возвращает конфиг
 func Load() *Config {
 	c := &Config{}
+	flag.StringVar(&c.Transport, "transport", "tcp", "link to the device: tcp | udp (-host/-port) | unix (-socket) | serial (-device, Linux only)")
 	flag.StringVar(&c.Host, "host", "127.0.0.1", "server host")
 	flag.IntVar(&c.Port, "port", 9000, "server port")
-	flag.StringVar(&c.CRCMode, "crc", "sum", "crc mode: sum | crc16")
+	flag.StringVar(&c.Socket, "socket", "", "unix socket path for -transport unix")
+	flag.BoolVar(&c.TLS, "tls", false, "use TLS over tcp or unix transport")
+	flag.StringVar(&c.TLSCA, "tlsca", "", "CA certificates PEM file to verify the server (empty = system roots)")
+	flag.StringVar(&c.TLSCert, "tlscert", "", "client certificate PEM file for mutual TLS")
+	flag.StringVar(&c.TLSKey, "tlskey", "", "client private key PEM file for mutual TLS")
+	flag.StringVar(&c.TLSServerName, "tlsservername", "", "server name for SNI and certificate verification (empty = -host)")
+	flag.BoolVar(&c.TLSInsecure, "tlsinsecure", false, "do not verify the server certificate (tests only)")
+	flag.StringVar(&c.Device, "device", "", "serial device for -transport serial, e.g. /dev/ttyUSB0")
+	flag.IntVar(&c.Baud, "baud", 9600, "serial baud rate")
+	flag.IntVar(&c.DataBits, "databits", 8, "serial data bits (5..8)")
+	flag.StringVar(&c.Parity, "parity", "E", "serial parity: N | E | O (FT1.2 uses 8E1)")
+	flag.IntVar(&c.StopBits, "stopbits", 1, "serial stop bits (1 | 2)")
+	flag.StringVar(&c.CRCMode, "crc", "sum", "checksum algorithm: sum | crc16 | xor8 | crc8 | crc16-be | crc16-ccitt | crc16-x25 | crc16-kermit | name from -crcdef")
+	flag.StringVar(&c.Dialect, "dialect", "simplified", "frame dialect: simplified (68 L 68) | ft12 (68 L L 68)")
+	flag.BoolVar(&c.StrictCRC, "strict", false, "accept only frames signed with the configured -crc algorithm")
 	flag.IntVar(&c.AdapterAddr, "adapter", 1, "adapter address (0..255)")
 	flag.IntVar(&c.TimeoutMs, "timeout", 1000, "timeout for response in milliseconds")
 	flag.IntVar(&c.Retries, "retries", 2, "number of retries on timeout/error")
 	flag.StringVar(&c.LogFile, "log", "", "log file (empty = stdout)")
 	flag.IntVar(&c.PollEverySec, "pollstep", 1, "polling tick step in seconds (default 1)")
+	flag.BoolVar(&c.LinkReset, "linkreset", false, "send FT1.2 reset-link short frame on every new connection and expect confirmation (0xE5)")
+	flag.IntVar(&c.ArchiveLen, "archive", 0, "read N bytes of device archive once at startup via multi-frame transfer (0 = off)")
+	flag.StringVar(&c.TimeFormat, "timefmt", "ascii", "time format in read-time responses: ascii | cp56 (CP56Time2a) | bcd")
+	flag.StringVar(&c.SetTime, "settime", "", "set device clock once at startup (command 0x03): now | \"YYYY-MM-DD HH:MM:SS\" (empty = off)")
+	flag.IntVar(&c.SyncDrift, "syncdrift", 0, "set device clock when drift from host time exceeds N seconds (0 = off)")
+	flag.StringVar(&c.RegMap, "regmap", "", "JSON register map of the device (required for -read / -write)")
+	flag.StringVar(&c.ReadParams, "read", "", "comma-separated parameter names to read once at startup")
+	flag.Var(&c.WriteParams, "write", "name=value parameter to write once at startup (repeatable)")
+	flag.StringVar(&c.Record, "record", "", "record all TX/RX chunks with timestamps to a JSONL capture file")
+	flag.StringVar(&c.Pcap, "pcap", "", "write all TX/RX chunks to a libpcap file with synthesized Ethernet/IP/TCP headers (for Wireshark)")
+	flag.StringVar(&c.Replay, "replay", "", "replay a capture file to the emulator as the polling side instead of polling, then exit")
+	flag.Float64Var(&c.ReplaySpeed, "replayspeed", 1, "replay pace: 1 = original timing, N = N times faster, 0 = no pauses")
+	flag.Var(&c.CRCDefs, "crcdef", "extra CRC definition name:width=16,poly=0x1021,init=0xFFFF,reflect=false,xorout=0,order=be (repeatable)")
 	flag.Parse()
 	return c
 }
+
+// stringList - флаг, который можно указать несколько раз
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, "; ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -6,7 +6,11 @@ import (
 	"sln/client/internal/client"
 	"sln/client/internal/config"
 	"sln/client/internal/logging"
+	"sln/ft12"
+	"sln/ft12/regmap"
+	"strings"
 	"syscall"
+	"time"
 )
 
 // Точка входа клиента. Загружает конфиг, запускает опрашивающий клиент
@@ -15,8 +19,93 @@ func main() {
 	cfg := config.Load()
 	logger := logging.New(cfg.LogFile)
 
-	logger.Printf("starting ttp20 client (target=%s:%d adapter=%d crc=%s timeout=%dms retries=%d)",
-		cfg.Host, cfg.Port, cfg.AdapterAddr, cfg.CRCMode, cfg.TimeoutMs, cfg.Retries)
+	if _, err := ft12.ParseDialect(cfg.Dialect); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	for _, def := range cfg.CRCDefs {
+		if err := ft12.RegisterCRCDef(def); err != nil {
+			logger.Fatalf("invalid config: %v", err)
+		}
+	}
+	if _, err := ft12.LookupChecksum(cfg.CRCMode); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	if _, err := ft12.LookupTimeCodec(cfg.TimeFormat); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	var setTime time.Time
+	switch cfg.SetTime {
+	case "", "now":
+		// "now" - время хоста на момент отправки команды
+	default:
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", cfg.SetTime, time.Local)
+		if err != nil {
+			logger.Fatalf("invalid config: settime: %v", err)
+		}
+		setTime = t
+	}
+	tr, err := client.NewTransport(cfg)
+	if err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	if cfg.ReplaySpeed < 0 {
+		logger.Fatalf("invalid config: replayspeed %g must not be negative", cfg.ReplaySpeed)
+	}
+	if cfg.SyncDrift < 0 {
+		logger.Fatalf("invalid config: syncdrift %d must not be negative", cfg.SyncDrift)
+	}
+
+	// Параметры прибора для разового чтения/записи проверяем по карте регистров до подключения
+	var regs *regmap.Map
+	if cfg.RegMap != "" {
+		m, err := regmap.Load(cfg.RegMap)
+		if err != nil {
+			logger.Fatalf("invalid config: regmap: %v", err)
+		}
+		regs = m
+	}
+	type paramWrite struct {
+		reg *regmap.Register
+		val regmap.Value
+	}
+	var reads []*regmap.Register
+	var writes []paramWrite
+	if (cfg.ReadParams != "" || len(cfg.WriteParams) > 0) && regs == nil {
+		logger.Fatalf("invalid config: -read and -write need -regmap")
+	}
+	if cfg.ReadParams != "" {
+		for _, name := range strings.Split(cfg.ReadParams, ",") {
+			reg, ok := regs.ByName(strings.TrimSpace(name))
+			if !ok {
+				logger.Fatalf("invalid config: read: unknown parameter %q", name)
+			}
+			reads = append(reads, reg)
+		}
+	}
+	for _, w := range cfg.WriteParams {
+		name, val, ok := strings.Cut(w, "=")
+		reg, found := regs.ByName(name)
+		if !ok || !found {
+			logger.Fatalf("invalid config: write %q: want known_name=value", w)
+		}
+		v, err := reg.ParseValue(val)
+		if err != nil {
+			logger.Fatalf("invalid config: write: %v", err)
+		}
+		writes = append(writes, paramWrite{reg, v})
+	}
+
+	logger.Printf("starting ttp20 client (target=%s adapter=%d crc=%s dialect=%s timefmt=%s timeout=%dms retries=%d)",
+		tr, cfg.AdapterAddr, cfg.CRCMode, cfg.Dialect, cfg.TimeFormat, cfg.TimeoutMs, cfg.Retries)
+
+	// Воспроизведение записи вместо опроса
+	if cfg.Replay != "" {
+		if err := client.Replay(cfg, logger); err != nil {
+			logger.Fatalf("replay failed: %v", err)
+		}
+		logger.Println("replay finished")
+		return
+	}
 
 	cl := client.NewClient(cfg, logger)
 
@@ -25,6 +114,43 @@ func main() {
 		logger.Fatalf("client start failed: %v", err)
 	}
 
+	// Разовая установка часов устройства
+	if cfg.SetTime != "" {
+		if cfg.SetTime == "now" {
+			setTime = time.Now()
+		}
+		if err := cl.SetTime(setTime); err != nil {
+			logger.Printf("set time failed: %v", err)
+		}
+	}
+
+	// Разовая запись и чтение параметров
+	for _, w := range writes {
+		if err := cl.WriteParam(w.reg, w.val); err != nil {
+			logger.Printf("write %s failed: %v", w.reg.Name, err)
+		} else {
+			logger.Printf("param %s set to %s", w.reg.Name, w.reg.Format(w.val))
+		}
+	}
+	for _, reg := range reads {
+		v, err := cl.ReadParam(reg)
+		if err != nil {
+			logger.Printf("read %s failed: %v", reg.Name, err)
+		} else {
+			logger.Printf("param %s = %s", reg.Name, reg.Format(v))
+		}
+	}
+
+	// Разовое чтение архива (многокадровый ответ)
+	if cfg.ArchiveLen > 0 {
+		data, err := cl.ReadArchive(cfg.ArchiveLen)
+		if err != nil {
+			logger.Printf("archive read failed: %v", err)
+		} else {
+			logger.Printf("archive: %d bytes read", len(data))
+		}
+	}
+
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 
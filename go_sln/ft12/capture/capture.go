@@ -0,0 +1,258 @@
+// Package capture записывает обмен по соединениям в файл JSONL (и в .pcap) и воспроизводит его.
+//
+// Первая строка файла - заголовок, далее по одной записи на каждый прочитанный или
+// отправленный кусок байт:
+//
+//	{"format":"ft12-capture","version":1,"side":"client","started":"2026-10-17T13:00:00Z"}
+//	{"t":"2026-10-17T13:00:05.000412Z","conn":"127.0.0.1:50112->127.0.0.1:9000","dir":"tx","data":"68 03 68 73 01 01 75 16"}
+//	{"t":"2026-10-17T13:00:05.001270Z","conn":"127.0.0.1:50112->127.0.0.1:9000","dir":"rx","data":"68 16 68 88 ..."}
+//
+// dir указывается относительно записывающей стороны (side): tx - отправлено, rx - получено.
+// Куски записываются в том виде, в каком их вернул Read/Write, то есть с реальной фрагментацией.
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format - значение поля format в заголовке
+const Format = "ft12-capture"
+
+// Side - кто записал файл
+type Side string
+
+const (
+	SideClient Side = "client" // опрашивающая сторона (клиент)
+	SideServer Side = "server" // прибор (эмулятор)
+)
+
+// Dir - направление куска относительно записывающей стороны
+type Dir string
+
+const (
+	DirTX Dir = "tx"
+	DirRX Dir = "rx"
+)
+
+// Header - первая строка файла
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Side    Side      `json:"side"`
+	Started time.Time `json:"started"`
+}
+
+// Record - один кусок байт
+type Record struct {
+	Time time.Time `json:"t"`
+	Conn string    `json:"conn"` // "local->remote" записывающей стороны
+	Dir  Dir       `json:"dir"`
+	Data HexBytes  `json:"data"`
+}
+
+// HexBytes сериализуется в JSON как hex-дамп "68 03 68 ..." (как в логах)
+type HexBytes []byte
+
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	parts := make([]string, len(h))
+	for i, b := range h {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return json.Marshal(strings.Join(parts, " "))
+}
+
+func (h *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return fmt.Errorf("bad hex data: %v", err)
+	}
+	*h = b
+	return nil
+}
+
+// Recorder пишет записи в файл; безопасен для использования из нескольких соединений
+type Recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// Create создаёт (перезаписывает) файл записи и пишет заголовок
+func Create(path string, side Side) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{f: f, enc: json.NewEncoder(f)}
+	r.enc.SetEscapeHTML(false) // иначе "->" в conn превращается в "-\u003e"
+	if err := r.enc.Encode(Header{Format: Format, Version: 1, Side: side, Started: time.Now().UTC()}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record записывает кусок данных соединения conn
+func (r *Recorder) Record(conn string, dir Dir, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	rec := Record{Time: time.Now().UTC(), Conn: conn, Dir: dir, Data: append(HexBytes(nil), b...)}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Ошибка записи не должна ломать обмен: запись - вспомогательная функция
+	_ = r.enc.Encode(rec)
+}
+
+// Close закрывает файл
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// Wrap возвращает соединение, все Read/Write которого записываются
+func (r *Recorder) Wrap(c net.Conn) net.Conn {
+	id := ConnID(c)
+	return &tapConn{Conn: c, chunk: func(dir Dir, b []byte) { r.Record(id, dir, b) }}
+}
+
+// ConnID - идентификатор соединения в записи: "local->remote"
+func ConnID(c net.Conn) string {
+	return c.LocalAddr().String() + "->" + c.RemoteAddr().String()
+}
+
+// tapConn передаёт копию каждого прочитанного/отправленного куска в chunk, а закрытие - в closed
+type tapConn struct {
+	net.Conn
+	chunk  func(dir Dir, b []byte)
+	closed func()
+	once   sync.Once
+}
+
+func (c *tapConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.chunk(DirRX, p[:n])
+	}
+	return n, err
+}
+
+func (c *tapConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.chunk(DirTX, p[:n])
+	}
+	return n, err
+}
+
+func (c *tapConn) Close() error {
+	if c.closed != nil {
+		c.once.Do(c.closed)
+	}
+	return c.Conn.Close()
+}
+
+// Capture - загруженный файл записи
+type Capture struct {
+	Header
+	Records []Record
+}
+
+// Load читает файл записи
+func Load(path string) (*Capture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var c Capture
+	line := 0
+	for sc.Scan() {
+		line++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		if line == 1 {
+			if err := json.Unmarshal(sc.Bytes(), &c.Header); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+			if c.Format != Format {
+				return nil, fmt.Errorf("%s: not a capture file (format %q)", path, c.Format)
+			}
+			if c.Side != SideClient && c.Side != SideServer {
+				return nil, fmt.Errorf("%s: unknown side %q", path, c.Side)
+			}
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if r.Dir != DirTX && r.Dir != DirRX {
+			return nil, fmt.Errorf("%s:%d: unknown dir %q", path, line, r.Dir)
+		}
+		c.Records = append(c.Records, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if line == 0 {
+		return nil, fmt.Errorf("%s: empty capture", path)
+	}
+	return &c, nil
+}
+
+// FromDevice - кусок отправлен прибором (эмулятором), а не опрашивающей стороной
+func (c *Capture) FromDevice(r Record) bool {
+	return (c.Side == SideServer) == (r.Dir == DirTX)
+}
+
+// Connections возвращает записи, сгруппированные по соединениям, в порядке их появления
+func (c *Capture) Connections() [][]Record {
+	index := map[string]int{}
+	var out [][]Record
+	for _, r := range c.Records {
+		i, ok := index[r.Conn]
+		if !ok {
+			i = len(out)
+			index[r.Conn] = i
+			out = append(out, nil)
+		}
+		out[i] = append(out[i], r)
+	}
+	return out
+}
+
+// Streams переводит записанные соединения в потоки, как из .pcap: так один и тот же
+// разбор работает и для записи -record, и для tcpdump
+func (c *Capture) Streams() []*Stream {
+	var out []*Stream
+	for _, recs := range c.Connections() {
+		local, remote, _ := strings.Cut(recs[0].Conn, "->")
+		st := &Stream{Poller: local, Device: remote}
+		if c.Side == SideServer {
+			st.Poller, st.Device = remote, local
+		}
+		for _, r := range recs {
+			st.Chunks = append(st.Chunks, Chunk{Time: r.Time, FromDevice: c.FromDevice(r), Data: r.Data})
+		}
+		out = append(out, st)
+	}
+	return out
+}
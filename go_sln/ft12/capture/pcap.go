@@ -0,0 +1,347 @@
+package capture
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Запись обмена в формате libpcap для Wireshark. Настоящих пакетов у нас нет - есть только
+// куски, прочитанные из соединения и записанные в него, поэтому вокруг каждого куска
+// синтезируются заголовки того транспорта, по которому он действительно шёл:
+//   - TCP: Ethernet, IPv4/IPv6 и TCP с реальными адресами и портами. Номера последовательности
+//     сквозные, так что Wireshark собирает поток, а диссектор FT1.2/IEC 101 (Decode As... по порту)
+//     видит кадры. В начале соединения пишется трёхстороннее рукопожатие, при закрытии - FIN;
+//   - UDP: Ethernet, IPv4/IPv6 и UDP, одна датаграмма на кусок, без рукопожатия;
+//   - без IP (unix-сокет, псевдотерминал, последовательный порт): кадр Ethernet с EtherType
+//     0x88B5 (IEEE 802 local experimental) и байтами обмена как есть. Направление видно
+//     по MAC-адресам 02:00:00:NN:NN:RR (NNNN - номер соединения, RR - роль: 01 опрашивающая
+//     сторона, 02 прибор); выдуманных IP и TCP в файле нет
+
+const (
+	pcapMagic    = 0xa1b2c3d4 // микросекундные метки, little-endian
+	pcapSnapLen  = 65535
+	linkEthernet = 1
+	maxSegment   = 1460 // TCP payload одного синтезированного пакета
+	maxDatagram  = 1472 // UDP payload одного пакета; длиннее - несколько пакетов
+	maxRaw       = 1500 // данные одного кадра без IP
+
+	etherIPv4  = 0x0800
+	etherIPv6  = 0x86DD
+	etherLocal = 0x88B5 // IEEE 802 local experimental EtherType 1: обмен без IP
+
+	protoTCP = 6
+	protoUDP = 17
+
+	// Роли сторон в MAC обмена без IP (последний байт, см. rawMAC)
+	rawPoller = 0x01
+	rawDevice = 0x02
+)
+
+// Флаги TCP
+const (
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpPSH = 0x08
+	tcpACK = 0x10
+)
+
+// PcapWriter пишет синтезированные пакеты в файл .pcap; безопасен для нескольких соединений
+type PcapWriter struct {
+	mu    sync.Mutex
+	f     *os.File
+	side  Side
+	ipID  uint16
+	links uint16 // счётчик соединений без IP, для их MAC-адресов
+}
+
+// CreatePcap создаёт (перезаписывает) файл .pcap. side определяет, кто открыл соединение
+// в рукопожатии: клиент подключается сам, эмулятор принимает подключение
+func CreatePcap(path string, side Side) (*PcapWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], 2) // версия 2.4
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], linkEthernet)
+	if _, err := f.Write(hdr); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &PcapWriter{f: f, side: side}, nil
+}
+
+// Close закрывает файл
+func (w *PcapWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// Wrap возвращает соединение, обмен которого пишется в .pcap. Вид пакетов зависит от адресов
+// соединения: TCP, UDP или кадры без IP (см. выше)
+func (w *PcapWriter) Wrap(c net.Conn) net.Conn {
+	local, lok := endpointOf(c.LocalAddr())
+	remote, rok := endpointOf(c.RemoteAddr())
+	if !lok || !rok {
+		w.mu.Lock()
+		w.links++
+		s := &rawStream{w: w, local: rawMAC(w.links, rawPoller), remote: rawMAC(w.links, rawDevice)}
+		if w.side == SideServer {
+			s.local, s.remote = s.remote, s.local
+		}
+		w.mu.Unlock()
+		return &tapConn{Conn: c, chunk: s.chunk}
+	}
+	path := ipPath{w: w, local: local, remote: remote, v6: local.ip.To4() == nil || remote.ip.To4() == nil}
+	if _, ok := c.RemoteAddr().(*net.UDPAddr); ok {
+		s := &udpStream{ipPath: path}
+		return &tapConn{Conn: c, chunk: s.chunk}
+	}
+	s := &tcpStream{ipPath: path}
+	s.handshake(w.side == SideClient)
+	return &tapConn{Conn: c, chunk: s.chunk, closed: s.fin}
+}
+
+type endpoint struct {
+	ip   net.IP
+	port uint16
+}
+
+// endpointOf извлекает IP и порт из адреса TCP или UDP; false - адрес без IP (unix, порт, pipe)
+func endpointOf(a net.Addr) (endpoint, bool) {
+	switch t := a.(type) {
+	case *net.TCPAddr:
+		if t.IP != nil {
+			return endpoint{ip: t.IP, port: uint16(t.Port)}, true
+		}
+	case *net.UDPAddr:
+		if t.IP != nil {
+			return endpoint{ip: t.IP, port: uint16(t.Port)}, true
+		}
+	}
+	return endpoint{}, false
+}
+
+// mac - синтетический локально администрируемый MAC, по одному на IP
+func (e endpoint) mac() []byte {
+	ip := e.ip.To16()
+	return []byte{0x02, 0x00, ip[12], ip[13], ip[14], ip[15]}
+}
+
+// rawMAC - синтетический MAC стороны соединения без IP: link - номер соединения,
+// role - rawPoller или rawDevice (по нему ReadPcap находит прибор)
+func rawMAC(link uint16, role byte) []byte {
+	return []byte{0x02, 0x00, 0x00, byte(link >> 8), byte(link), role}
+}
+
+// ipPath - адреса соединения поверх IP
+type ipPath struct {
+	w             *PcapWriter
+	local, remote endpoint
+	v6            bool
+}
+
+// tcpStream - состояние одного TCP-соединения: номера последовательности обеих сторон
+type tcpStream struct {
+	ipPath
+	localSeq, remoteSeq uint32
+}
+
+// handshake пишет SYN, SYN-ACK, ACK; localOpens - соединение открыла локальная сторона
+func (s *tcpStream) handshake(localOpens bool) {
+	s.packet(localOpens, tcpSYN, nil)
+	s.packet(!localOpens, tcpSYN|tcpACK, nil)
+	s.packet(localOpens, tcpACK, nil)
+}
+
+func (s *tcpStream) chunk(dir Dir, b []byte) {
+	for len(b) > 0 {
+		n := min(len(b), maxSegment)
+		s.packet(dir == DirTX, tcpPSH|tcpACK, b[:n])
+		b = b[n:]
+	}
+}
+
+func (s *tcpStream) fin() {
+	s.packet(true, tcpFIN|tcpACK, nil)
+}
+
+// packet пишет один TCP-пакет от локальной (fromLocal) или удалённой стороны и сдвигает её номер
+func (s *tcpStream) packet(fromLocal bool, flags byte, payload []byte) {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+
+	src, dst := s.remote, s.local
+	seq, ack := &s.remoteSeq, s.localSeq
+	if fromLocal {
+		src, dst = s.local, s.remote
+		seq, ack = &s.localSeq, s.remoteSeq
+	}
+	if flags&tcpACK == 0 {
+		ack = 0
+	}
+
+	tcp := make([]byte, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:], src.port)
+	binary.BigEndian.PutUint16(tcp[2:], dst.port)
+	binary.BigEndian.PutUint32(tcp[4:], *seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = 5 << 4 // длина заголовка 20 байт
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:], 0xFFFF) // окно
+	copy(tcp[20:], payload)
+	s.write(fromLocal, protoTCP, tcp, 16)
+
+	*seq += uint32(len(payload))
+	if flags&(tcpSYN|tcpFIN) != 0 {
+		*seq++
+	}
+}
+
+// udpStream - «соединение» UDP: каждый кусок - отдельная датаграмма
+type udpStream struct {
+	ipPath
+}
+
+func (s *udpStream) chunk(dir Dir, b []byte) {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	src, dst := s.remote, s.local
+	if dir == DirTX {
+		src, dst = s.local, s.remote
+	}
+	for len(b) > 0 {
+		n := min(len(b), maxDatagram)
+		udp := make([]byte, 8+n)
+		binary.BigEndian.PutUint16(udp[0:], src.port)
+		binary.BigEndian.PutUint16(udp[2:], dst.port)
+		binary.BigEndian.PutUint16(udp[4:], uint16(len(udp)))
+		copy(udp[8:], b[:n])
+		s.write(dir == DirTX, protoUDP, udp, 6)
+		b = b[n:]
+	}
+}
+
+// write дописывает к сегменту транспортного уровня l4 заголовки IP и Ethernet и пишет пакет;
+// csumAt - смещение контрольной суммы в l4. Вызывается под w.mu
+func (p *ipPath) write(fromLocal bool, proto byte, l4 []byte, csumAt int) {
+	src, dst := p.remote, p.local
+	if fromLocal {
+		src, dst = p.local, p.remote
+	}
+	var ip []byte
+	etherType := uint16(etherIPv4)
+	if p.v6 {
+		etherType = etherIPv6
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(l4)))
+		ip[6] = proto
+		ip[7] = 64
+		copy(ip[8:], src.ip.To16())
+		copy(ip[24:], dst.ip.To16())
+		pseudo := make([]byte, 40)
+		copy(pseudo, ip[8:40])
+		binary.BigEndian.PutUint32(pseudo[32:], uint32(len(l4)))
+		pseudo[39] = proto
+		binary.BigEndian.PutUint16(l4[csumAt:], l4Checksum(proto, pseudo, l4))
+	} else {
+		p.w.ipID++
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(l4)))
+		binary.BigEndian.PutUint16(ip[4:], p.w.ipID)
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // DF
+		ip[8] = 64
+		ip[9] = proto
+		copy(ip[12:], src.ip.To4())
+		copy(ip[16:], dst.ip.To4())
+		binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+		pseudo := make([]byte, 12)
+		copy(pseudo, ip[12:20])
+		pseudo[9] = proto
+		binary.BigEndian.PutUint16(pseudo[10:], uint16(len(l4)))
+		binary.BigEndian.PutUint16(l4[csumAt:], l4Checksum(proto, pseudo, l4))
+	}
+	p.w.frame(dst.mac(), src.mac(), etherType, ip, l4)
+}
+
+// l4Checksum - контрольная сумма TCP/UDP; у UDP нулевая сумма означает «не вычислена» и передаётся как 0xFFFF
+func l4Checksum(proto byte, pseudo, l4 []byte) uint16 {
+	sum := checksum(pseudo, l4)
+	if proto == protoUDP && sum == 0 {
+		sum = 0xFFFF
+	}
+	return sum
+}
+
+// rawStream - соединение без IP: кадры Ethernet с EtherType etherLocal
+type rawStream struct {
+	w             *PcapWriter
+	local, remote []byte // MAC
+}
+
+func (s *rawStream) chunk(dir Dir, b []byte) {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	src, dst := s.remote, s.local
+	if dir == DirTX {
+		src, dst = s.local, s.remote
+	}
+	for len(b) > 0 {
+		n := min(len(b), maxRaw)
+		s.w.frame(dst, src, etherLocal, b[:n])
+		b = b[n:]
+	}
+}
+
+// frame пишет запись с кадром Ethernet; вызывается под w.mu
+func (w *PcapWriter) frame(dst, src []byte, etherType uint16, parts ...[]byte) {
+	frame := make([]byte, 0, 1600)
+	frame = append(frame, dst...)
+	frame = append(frame, src...)
+	frame = binary.BigEndian.AppendUint16(frame, etherType)
+	for _, p := range parts {
+		frame = append(frame, p...)
+	}
+
+	now := time.Now()
+	rec := make([]byte, 16, 16+len(frame))
+	binary.LittleEndian.PutUint32(rec[0:], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+	// Ошибка записи не должна ломать обмен (как и в Recorder)
+	_, _ = w.f.Write(append(rec, frame...))
+}
+
+// checksum - контрольная сумма Интернета (RFC 1071) по склеенным частям
+func checksum(parts ...[]byte) uint16 {
+	var sum uint32
+	var odd bool
+	var hi byte
+	for _, p := range parts {
+		for _, b := range p {
+			if odd {
+				sum += uint32(hi)<<8 | uint32(b)
+			} else {
+				hi = b
+			}
+			odd = !odd
+		}
+	}
+	if odd {
+		sum += uint32(hi) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,412 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Чтение .pcap (например, снятого tcpdump на объекте) и сборка потоков к прибору: TCP и UDP
+// по порту прибора, а также обмен без IP, записанный PcapWriter (EtherType 0x88B5).
+// Поддерживается классический libpcap (обе разрядности байт, микро- и наносекундные метки)
+// с заголовками Ethernet (в т.ч. VLAN), Linux cooked (SLL/SLL2), BSD loopback и raw IP.
+// pcapng не поддерживается: его можно перевести командой editcap -F pcap
+
+// Типы канального уровня (LINKTYPE_*)
+const (
+	linkNull  = 0
+	linkRaw   = 101
+	linkSLL   = 113
+	linkIPv4  = 228
+	linkIPv6  = 229
+	linkSLL2  = 276
+	linkRawBS = 12 // raw IP в старых BSD
+)
+
+// maxCapLen - предел длины записи, если snaplen в заголовке не задан или больше:
+// 256 КиБ, как у tcpdump по умолчанию
+const maxCapLen = 256 << 10
+
+// Packet - один пакет из файла
+type Packet struct {
+	Time time.Time
+	Data []byte
+}
+
+// PcapFile - содержимое файла .pcap
+type PcapFile struct {
+	LinkType uint32
+	Packets  []Packet
+}
+
+// ReadPcap читает файл .pcap целиком
+func ReadPcap(path string) (*PcapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	hdr := make([]byte, 24)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("%s: pcap header: %v", path, err)
+	}
+	var order binary.ByteOrder
+	nano := false
+	switch binary.LittleEndian.Uint32(hdr) {
+	case 0xa1b2c3d4:
+		order = binary.LittleEndian
+	case 0xa1b23c4d:
+		order, nano = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		order = binary.BigEndian
+	case 0x4d3cb2a1:
+		order, nano = binary.BigEndian, true
+	case 0x0a0d0d0a:
+		return nil, fmt.Errorf("%s: pcapng is not supported (convert with: editcap -F pcap in.pcapng out.pcap)", path)
+	default:
+		return nil, fmt.Errorf("%s: not a pcap file", path)
+	}
+
+	pf := &PcapFile{LinkType: order.Uint32(hdr[20:]) & 0x0FFFFFFF}
+	// Длина записи берётся из файла: без предела один испорченный заголовок записи
+	// заставил бы выделить до 4 ГиБ
+	limit := order.Uint32(hdr[16:])
+	if limit == 0 || limit > maxCapLen {
+		limit = maxCapLen
+	}
+	rec := make([]byte, 16)
+	for {
+		// Конец файла или обрезанный хвост (tcpdump прервали) - отдаём то, что успели прочитать
+		if _, err := io.ReadFull(r, rec); err != nil {
+			return pf, nil
+		}
+		sec, frac := int64(order.Uint32(rec[0:])), int64(order.Uint32(rec[4:]))
+		if !nano {
+			frac *= 1000
+		}
+		caplen := order.Uint32(rec[8:])
+		if caplen > limit {
+			return nil, fmt.Errorf("%s: packet %d: captured length %d exceeds snaplen %d (corrupt file)", path, len(pf.Packets)+1, caplen, limit)
+		}
+		data := make([]byte, caplen)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return pf, nil
+		}
+		pf.Packets = append(pf.Packets, Packet{Time: time.Unix(sec, frac), Data: data})
+	}
+}
+
+// Транспорт сегмента (Segment.Transport)
+const (
+	TransportTCP = "tcp"
+	TransportUDP = "udp"
+	TransportRaw = "raw" // кадр Ethernet без IP (EtherType 0x88B5) от PcapWriter
+)
+
+// Segment - данные одного пакета: TCP-сегмент, UDP-датаграмма или кадр без IP
+type Segment struct {
+	Time      time.Time
+	Transport string
+	Src, Dst  string // "ip:port"; у кадра без IP - MAC
+	SrcPort   uint16
+	DstPort   uint16
+	Seq       uint32 // только TCP
+	Flags     byte   // только TCP
+	Payload   []byte
+}
+
+// ParseSegment снимает заголовки канального уровня, IP и TCP или UDP.
+// Кадр Ethernet с EtherType 0x88B5 отдаётся целиком как данные, адреса - MAC.
+// false - пакет не TCP, не UDP и не кадр без IP
+func ParseSegment(linkType uint32, p Packet) (Segment, bool) {
+	b := p.Data
+	var etherType uint16
+	var srcMAC, dstMAC net.HardwareAddr
+	switch linkType {
+	case linkEthernet:
+		if len(b) < 14 {
+			return Segment{}, false
+		}
+		dstMAC, srcMAC = net.HardwareAddr(b[0:6]), net.HardwareAddr(b[6:12])
+		etherType, b = binary.BigEndian.Uint16(b[12:]), b[14:]
+		for (etherType == 0x8100 || etherType == 0x88A8) && len(b) >= 4 {
+			etherType, b = binary.BigEndian.Uint16(b[2:]), b[4:]
+		}
+	case linkSLL:
+		if len(b) < 16 {
+			return Segment{}, false
+		}
+		etherType, b = binary.BigEndian.Uint16(b[14:]), b[16:]
+	case linkSLL2:
+		if len(b) < 20 {
+			return Segment{}, false
+		}
+		etherType, b = binary.BigEndian.Uint16(b[0:]), b[20:]
+	case linkNull:
+		if len(b) < 4 {
+			return Segment{}, false
+		}
+		b = b[4:]
+	case linkRaw, linkRawBS, linkIPv4, linkIPv6:
+	default:
+		return Segment{}, false
+	}
+	if etherType == etherLocal && srcMAC != nil {
+		return Segment{
+			Time:      p.Time,
+			Transport: TransportRaw,
+			Src:       srcMAC.String(),
+			Dst:       dstMAC.String(),
+			Payload:   b,
+		}, true
+	}
+	if etherType != 0 && etherType != etherIPv4 && etherType != etherIPv6 {
+		return Segment{}, false
+	}
+	if len(b) == 0 {
+		return Segment{}, false
+	}
+
+	var src, dst net.IP
+	var proto byte
+	var l4 []byte
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return Segment{}, false
+		}
+		ihl := int(b[0]&0x0F) * 4
+		total := int(binary.BigEndian.Uint16(b[2:]))
+		// Фрагментированные IP-пакеты не собираем: для коротких кадров FT1.2 их не бывает
+		if ihl < 20 || total < ihl || total > len(b) || binary.BigEndian.Uint16(b[6:])&0x3FFF != 0 {
+			return Segment{}, false
+		}
+		src, dst, proto, l4 = net.IP(b[12:16]), net.IP(b[16:20]), b[9], b[ihl:total]
+	case 6:
+		if len(b) < 40 {
+			return Segment{}, false
+		}
+		end := 40 + int(binary.BigEndian.Uint16(b[4:]))
+		if end > len(b) {
+			return Segment{}, false
+		}
+		src, dst, proto, l4 = net.IP(b[8:24]), net.IP(b[24:40]), b[6], b[40:end]
+	default:
+		return Segment{}, false
+	}
+
+	seg := Segment{Time: p.Time}
+	switch proto {
+	case protoTCP:
+		if len(l4) < 20 {
+			return Segment{}, false
+		}
+		off := int(l4[12]>>4) * 4
+		if off < 20 || off > len(l4) {
+			return Segment{}, false
+		}
+		seg.Transport = TransportTCP
+		seg.Seq, seg.Flags, seg.Payload = binary.BigEndian.Uint32(l4[4:]), l4[13], l4[off:]
+	case protoUDP:
+		// Длина из заголовка UDP: хвост кадра Ethernet (выравнивание до 60 байт) - не данные
+		if len(l4) < 8 {
+			return Segment{}, false
+		}
+		n := int(binary.BigEndian.Uint16(l4[4:]))
+		if n < 8 || n > len(l4) {
+			return Segment{}, false
+		}
+		seg.Transport = TransportUDP
+		seg.Payload = l4[8:n]
+	default:
+		return Segment{}, false
+	}
+	seg.SrcPort, seg.DstPort = binary.BigEndian.Uint16(l4[0:]), binary.BigEndian.Uint16(l4[2:])
+	seg.Src = net.JoinHostPort(src.String(), strconv.Itoa(int(seg.SrcPort)))
+	seg.Dst = net.JoinHostPort(dst.String(), strconv.Itoa(int(seg.DstPort)))
+	return seg, true
+}
+
+// roles определяет опрашивающую сторону и прибор: у TCP и UDP - по порту прибора port,
+// у кадра без IP - по синтетическим MAC PcapWriter (см. rawMAC). false - пакет не к прибору
+func (s Segment) roles(port int) (poller, device string, ok bool) {
+	if s.Transport == TransportRaw {
+		src, sok := rawRole(s.Src)
+		dst, dok := rawRole(s.Dst)
+		switch {
+		case !sok || !dok || src == dst:
+			return "", "", false
+		case src == rawDevice:
+			return s.Dst, s.Src, true
+		}
+		return s.Src, s.Dst, true
+	}
+	switch {
+	case int(s.SrcPort) == port:
+		return s.Dst, s.Src, true
+	case int(s.DstPort) == port:
+		return s.Src, s.Dst, true
+	}
+	return "", "", false
+}
+
+// rawRole возвращает роль стороны по синтетическому MAC 02:00:00:NN:NN:RR; false - MAC чужой
+func rawRole(mac string) (byte, bool) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 || hw[0] != 0x02 || hw[1] != 0 || hw[2] != 0 {
+		return 0, false
+	}
+	if r := hw[5]; r == rawPoller || r == rawDevice {
+		return r, true
+	}
+	return 0, false
+}
+
+// Chunk - непрерывный кусок данных одного направления потока
+type Chunk struct {
+	Time       time.Time
+	FromDevice bool
+	Data       []byte
+}
+
+// Stream - собранное соединение между опрашивающей стороной и прибором
+type Stream struct {
+	Poller, Device string // "ip:port"; у обмена без IP - MAC
+	Chunks         []Chunk
+	Gaps           int // сколько раз в потоке не хватило данных (потерянные сегменты)
+}
+
+// Streams собирает из пакетов потоки к прибору: TCP и UDP, у которых одна из сторон - порт
+// прибора port, и обмен без IP (порт не нужен). У TCP повторы сегментов отбрасываются,
+// перестановки упорядочиваются по номеру последовательности; у UDP и обмена без IP каждый
+// пакет - очередной кусок потока
+func (pf *PcapFile) Streams(port int) []*Stream {
+	var out []*Stream
+	streams := map[string]*Stream{} // по "transport poller>device"
+	dirs := map[string]*tcpDir{}    // по "src>dst"
+
+	for _, p := range pf.Packets {
+		seg, ok := ParseSegment(pf.LinkType, p)
+		if !ok {
+			continue
+		}
+		poller, device, ok := seg.roles(port)
+		if !ok {
+			continue
+		}
+		key := seg.Transport + " " + poller + ">" + device
+		st := streams[key]
+		if seg.Transport != TransportTCP {
+			if st == nil {
+				st = &Stream{Poller: poller, Device: device}
+				streams[key] = st
+				out = append(out, st)
+			}
+			if len(seg.Payload) > 0 {
+				st.Chunks = append(st.Chunks, Chunk{Time: seg.Time, FromDevice: seg.Src == device, Data: seg.Payload})
+			}
+			continue
+		}
+		// SYN без ACK на уже известных адресах - новое соединение с теми же портами
+		if st == nil || seg.Flags&(tcpSYN|tcpACK) == tcpSYN && len(st.Chunks) > 0 {
+			for _, k := range []string{poller + ">" + device, device + ">" + poller} {
+				if d := dirs[k]; d != nil {
+					d.flush()
+					delete(dirs, k)
+				}
+			}
+			st = &Stream{Poller: poller, Device: device}
+			streams[key] = st
+			out = append(out, st)
+		}
+		dkey := seg.Src + ">" + seg.Dst
+		d := dirs[dkey]
+		if d == nil {
+			d = &tcpDir{st: st, fromDevice: seg.Src == device}
+			dirs[dkey] = d
+		}
+		d.add(seg)
+	}
+	// Хвосты после потерянных сегментов отдаём как есть, отметив разрыв
+	for _, d := range dirs {
+		d.flush()
+	}
+	for _, st := range out {
+		sort.SliceStable(st.Chunks, func(i, j int) bool { return st.Chunks[i].Time.Before(st.Chunks[j].Time) })
+	}
+	return out
+}
+
+// tcpDir - сборка одного направления TCP: ожидаемый номер и отложенные сегменты
+type tcpDir struct {
+	st         *Stream
+	fromDevice bool
+	started    bool
+	next       uint32
+	pending    []Segment
+}
+
+// add принимает сегмент и дописывает в поток данные, которые теперь идут подряд
+func (d *tcpDir) add(seg Segment) {
+	if seg.Flags&tcpSYN != 0 {
+		d.started, d.next, d.pending = true, seg.Seq+1, nil
+		return
+	}
+	if len(seg.Payload) == 0 {
+		return
+	}
+	if !d.started {
+		// Захват начат посреди соединения - синхронизируемся по первому сегменту с данными
+		d.started, d.next = true, seg.Seq
+	}
+	d.pending = append(d.pending, seg)
+	d.drain(seg.Time)
+}
+
+// drain выдаёт отложенные сегменты, начиная с ожидаемого номера; повторы отбрасывает.
+// Время куска - момент, когда данные стали доступны подряд (не раньше now)
+func (d *tcpDir) drain(now time.Time) {
+	for {
+		progressed := false
+		rest := d.pending[:0]
+		for _, s := range d.pending {
+			got := int32(d.next - s.Seq) // сколько байт сегмента уже получено
+			switch {
+			case got >= int32(len(s.Payload)):
+				// Повтор - уже получено целиком
+			case got >= 0:
+				t := s.Time
+				if t.Before(now) {
+					t = now
+				}
+				d.st.Chunks = append(d.st.Chunks, Chunk{Time: t, FromDevice: d.fromDevice, Data: s.Payload[got:]})
+				d.next = s.Seq + uint32(len(s.Payload))
+				progressed = true
+			default:
+				rest = append(rest, s)
+			}
+		}
+		d.pending = rest
+		if !progressed {
+			return
+		}
+	}
+}
+
+// flush выдаёт сегменты после разрывов (потерянных данных) по порядку номеров
+func (d *tcpDir) flush() {
+	for len(d.pending) > 0 {
+		sort.Slice(d.pending, func(i, j int) bool { return int32(d.pending[i].Seq-d.pending[j].Seq) < 0 })
+		d.st.Gaps++
+		d.next = d.pending[0].Seq
+		d.drain(time.Time{})
+	}
+}
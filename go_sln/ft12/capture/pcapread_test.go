@@ -0,0 +1,250 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConn - соединение с заданными адресами: Read отдаёт in, Write принимает всё
+type fakeConn struct {
+	in            *bytes.Reader
+	local, remote net.Addr
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)       { return c.in.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error)      { return len(p), nil }
+func (c *fakeConn) Close() error                     { return nil }
+func (c *fakeConn) LocalAddr() net.Addr              { return c.local }
+func (c *fakeConn) RemoteAddr() net.Addr             { return c.remote }
+func (c *fakeConn) SetDeadline(time.Time) error      { return nil }
+func (c *fakeConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+
+// Обмен, записанный PcapWriter, собирается Streams обратно в те же байты по направлениям
+// для каждого вида транспорта: TCP, UDP и обмена без IP
+func TestPcapRoundTrip(t *testing.T) {
+	request := []byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}
+	// Ответ длиннее одного пакета любого транспорта - проверяется и разбиение
+	response := make([]byte, 3000)
+	for i := range response {
+		response[i] = byte(i)
+	}
+	poller := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 49482}
+	device := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 20), Port: 9000}
+	poller6 := &net.TCPAddr{IP: net.ParseIP("fd00::5"), Port: 49482}
+	device6 := &net.TCPAddr{IP: net.ParseIP("fd00::20"), Port: 9000}
+	udpPoller := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 5000}
+	udpDevice := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 20), Port: 9000}
+	sock := &net.UnixAddr{Name: "/tmp/ttr20.sock", Net: "unix"}
+
+	tests := []struct {
+		name                   string
+		side                   Side
+		local, remote          net.Addr
+		wantPoller, wantDevice string
+	}{
+		{"tcp client", SideClient, poller, device, "10.0.0.5:49482", "10.0.0.20:9000"},
+		{"tcp server", SideServer, device, poller, "10.0.0.5:49482", "10.0.0.20:9000"},
+		{"tcp ipv6", SideClient, poller6, device6, "[fd00::5]:49482", "[fd00::20]:9000"},
+		{"udp client", SideClient, udpPoller, udpDevice, "10.0.0.5:5000", "10.0.0.20:9000"},
+		{"udp server", SideServer, udpDevice, udpPoller, "10.0.0.5:5000", "10.0.0.20:9000"},
+		{"unix client", SideClient, &net.UnixAddr{Net: "unix"}, sock, "02:00:00:00:01:01", "02:00:00:00:01:02"},
+		{"unix server", SideServer, sock, &net.UnixAddr{Net: "unix"}, "02:00:00:00:01:01", "02:00:00:00:01:02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "x.pcap")
+			w, err := CreatePcap(path, tt.side)
+			if err != nil {
+				t.Fatalf("CreatePcap: %v", err)
+			}
+			// Сторона, записывающая файл, отправляет свои байты и читает байты собеседника
+			ours, theirs := request, response
+			if tt.side == SideServer {
+				ours, theirs = response, request
+			}
+			c := w.Wrap(&fakeConn{in: bytes.NewReader(theirs), local: tt.local, remote: tt.remote})
+			if _, err := c.Write(ours); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			got := make([]byte, len(theirs))
+			if _, err := c.Read(got); err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			_ = c.Close()
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			pf, err := ReadPcap(path)
+			if err != nil {
+				t.Fatalf("ReadPcap: %v", err)
+			}
+			streams := pf.Streams(9000)
+			if len(streams) != 1 {
+				t.Fatalf("Streams: %d stream(s), want 1", len(streams))
+			}
+			st := streams[0]
+			if st.Poller != tt.wantPoller || st.Device != tt.wantDevice {
+				t.Errorf("stream %s <-> %s, want %s <-> %s", st.Poller, st.Device, tt.wantPoller, tt.wantDevice)
+			}
+			if st.Gaps != 0 {
+				t.Errorf("Gaps = %d, want 0", st.Gaps)
+			}
+			var fromPoller, fromDevice []byte
+			for _, ch := range st.Chunks {
+				if ch.FromDevice {
+					fromDevice = append(fromDevice, ch.Data...)
+				} else {
+					fromPoller = append(fromPoller, ch.Data...)
+				}
+			}
+			if !bytes.Equal(fromPoller, request) {
+				t.Errorf("poller -> device:\n got % X\nwant % X", fromPoller, request)
+			}
+			if !bytes.Equal(fromDevice, response) {
+				t.Errorf("device -> poller: got %d byte(s), want %d", len(fromDevice), len(response))
+			}
+		})
+	}
+}
+
+// tcpPacket собирает пакет raw IPv4 + TCP между 10.0.0.5:49482 и 10.0.0.20:9000
+func tcpPacket(ms int, fromDevice bool, seq uint32, flags byte, payload []byte) Packet {
+	b := make([]byte, 40+len(payload))
+	b[0], b[8], b[9] = 0x45, 64, protoTCP
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+	poller, device := []byte{10, 0, 0, 5}, []byte{10, 0, 0, 20}
+	sport, dport := uint16(49482), uint16(9000)
+	if fromDevice {
+		poller, device, sport, dport = device, poller, dport, sport
+	}
+	copy(b[12:], poller)
+	copy(b[16:], device)
+	binary.BigEndian.PutUint16(b[20:], sport)
+	binary.BigEndian.PutUint16(b[22:], dport)
+	binary.BigEndian.PutUint32(b[24:], seq)
+	b[32], b[33] = 5<<4, flags
+	copy(b[40:], payload)
+	return Packet{Time: time.Unix(1792200000, int64(ms)*int64(time.Millisecond)), Data: b}
+}
+
+// Сборка TCP: повторы отбрасываются, перестановки упорядочиваются, потерянный сегмент
+// отмечается разрывом, а SYN на тех же портах начинает новый поток
+func TestStreamsTCPReassembly(t *testing.T) {
+	const isn = 1000 // данные опрашивающей стороны начинаются с isn+1
+	tests := []struct {
+		name    string
+		packets []Packet
+		want    []string // данные опрашивающей стороны по потокам
+		gaps    int
+	}{
+		{"in order", []Packet{
+			tcpPacket(0, false, isn, tcpSYN, nil),
+			tcpPacket(1, false, isn+1, tcpACK, []byte("abc")),
+			tcpPacket(2, false, isn+4, tcpACK, []byte("def")),
+		}, []string{"abcdef"}, 0},
+		{"reordered", []Packet{
+			tcpPacket(0, false, isn, tcpSYN, nil),
+			tcpPacket(1, false, isn+4, tcpACK, []byte("def")),
+			tcpPacket(2, false, isn+1, tcpACK, []byte("abc")),
+		}, []string{"abcdef"}, 0},
+		{"retransmitted", []Packet{
+			tcpPacket(0, false, isn, tcpSYN, nil),
+			tcpPacket(1, false, isn+1, tcpACK, []byte("abc")),
+			tcpPacket(2, false, isn+1, tcpACK, []byte("abc")),
+			tcpPacket(3, false, isn+2, tcpACK, []byte("bcdef")), // перекрывает полученное
+		}, []string{"abcdef"}, 0},
+		{"lost segment", []Packet{
+			tcpPacket(0, false, isn, tcpSYN, nil),
+			tcpPacket(1, false, isn+1, tcpACK, []byte("abc")),
+			tcpPacket(2, false, isn+7, tcpACK, []byte("ghi")),
+		}, []string{"abcghi"}, 1},
+		{"capture started mid-connection", []Packet{
+			tcpPacket(0, false, 5000, tcpACK, []byte("abc")),
+			tcpPacket(1, false, 5003, tcpACK, []byte("def")),
+		}, []string{"abcdef"}, 0},
+		{"new connection on the same ports", []Packet{
+			tcpPacket(0, false, isn, tcpSYN, nil),
+			tcpPacket(1, false, isn+1, tcpACK, []byte("abc")),
+			tcpPacket(2, false, 7000, tcpSYN, nil),
+			tcpPacket(3, false, 7001, tcpACK, []byte("xyz")),
+		}, []string{"abc", "xyz"}, 0},
+	}
+	for _, tt := range tests {
+		pf := &PcapFile{LinkType: linkRaw, Packets: tt.packets}
+		streams := pf.Streams(9000)
+		var got []string
+		gaps := 0
+		for _, st := range streams {
+			var data []byte
+			for _, ch := range st.Chunks {
+				data = append(data, ch.Data...)
+			}
+			got = append(got, string(data))
+			gaps += st.Gaps
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || gaps != tt.gaps {
+			t.Errorf("%s: streams %q, %d gap(s); want %q, %d", tt.name, got, gaps, tt.want, tt.gaps)
+		}
+	}
+	// Пакеты к другому порту в потоки не попадают
+	pf := &PcapFile{LinkType: linkRaw, Packets: []Packet{tcpPacket(0, false, 1, tcpACK, []byte("abc"))}}
+	if streams := pf.Streams(502); len(streams) != 0 {
+		t.Errorf("Streams(502): %d stream(s), want 0", len(streams))
+	}
+}
+
+func TestReadPcapErrors(t *testing.T) {
+	hdr := func(magic, snaplen uint32) []byte {
+		b := make([]byte, 24)
+		binary.LittleEndian.PutUint32(b, magic)
+		binary.LittleEndian.PutUint32(b[16:], snaplen)
+		binary.LittleEndian.PutUint32(b[20:], linkRaw)
+		return b
+	}
+	record := func(caplen uint32, data []byte) []byte {
+		b := make([]byte, 16)
+		binary.LittleEndian.PutUint32(b[8:], caplen)
+		binary.LittleEndian.PutUint32(b[12:], caplen)
+		return append(b, data...)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		packets int
+		want    string // ожидаемая ошибка; пусто - без ошибки
+	}{
+		{"short header", []byte{0xd4, 0xc3, 0xb2, 0xa1}, 0, "pcap header"},
+		{"pcapng", append([]byte{0x0a, 0x0d, 0x0d, 0x0a}, make([]byte, 20)...), 0, "pcapng is not supported"},
+		{"not pcap", make([]byte, 24), 0, "not a pcap file"},
+		{"caplen over snaplen", append(hdr(0xa1b2c3d4, 64), record(65, make([]byte, 65))...), 0, "exceeds snaplen 64"},
+		{"caplen over default limit", append(hdr(0xa1b2c3d4, 0), record(maxCapLen+1, nil)...), 0, "exceeds snaplen"},
+		// Прерванный tcpdump: обрезанная последняя запись не считается ошибкой
+		{"truncated tail", append(append(hdr(0xa1b2c3d4, 64), record(4, []byte{1, 2, 3, 4})...), record(8, []byte{1})...), 1, ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "x.pcap")
+		if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		pf, err := ReadPcap(path)
+		if tt.want != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: ReadPcap error = %v, want %q", tt.name, err, tt.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ReadPcap: %v", tt.name, err)
+		} else if len(pf.Packets) != tt.packets {
+			t.Errorf("%s: %d packet(s), want %d", tt.name, len(pf.Packets), tt.packets)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"sln/ft12"
+	"time"
+)
+
+// Replayer воспроизводит одно записанное соединение в живое соединение conn.
+// Свои куски (ours) отправляются с исходными паузами между записями, делёнными на Speed.
+// Ответы другой стороны сравниваются с записью по кадрам, а не по кускам: записанные куски
+// и входящий поток разбираются кодеком Codec, поэтому кадр, пришедший иначе нарезанным,
+// совпадает с записью, а после таймаута или мусора сравнение продолжается со следующего
+// целого кадра. Так эмулятор играет роль прибора перед клиентом, а клиент - роль
+// опрашивающей стороны перед эмулятором, и обмен остаётся синхронным даже при ускорении
+type Replayer struct {
+	Speed float64       // 1 - исходный темп, 10 - в 10 раз быстрее, 0 - без пауз
+	Wait  time.Duration // сколько ждать кадры другой стороны сверх записанной паузы
+	Codec ft12.Codec    // разбор кадров записи и входящего потока
+	Logf  func(format string, args ...interface{})
+}
+
+// Stats - итог воспроизведения соединения
+type Stats struct {
+	Sent     int // отправлено кусков
+	Matched  int // получено кадров, совпавших с записью
+	Differed int // получено, но отличается от записи
+	Missing  int // не дождались (таймаут)
+}
+
+// Play воспроизводит записи recs; ours отбирает куски, которые отправляет эта сторона.
+// Непрочитанный остаток входящего потока сохраняется между кусками записи.
+// Возвращает ошибку только при обрыве соединения
+func (r *Replayer) Play(conn net.Conn, recs []Record, ours func(Record) bool) (Stats, error) {
+	var st Stats
+	want := ft12.NewDecoder(nil, r.Codec, 0) // кадры другой стороны из записи
+	in := &replayInput{r: r, conn: conn, dec: ft12.NewDecoder(conn, r.Codec, 0)}
+	for i, rec := range recs {
+		var gap time.Duration
+		if i > 0 {
+			gap = r.scale(rec.Time.Sub(recs[i-1].Time))
+		}
+		if ours(rec) {
+			time.Sleep(gap)
+			if _, err := conn.Write(rec.Data); err != nil {
+				return st, err
+			}
+			st.Sent++
+			r.logf("replay TX: % X", []byte(rec.Data))
+			continue
+		}
+
+		// Кусок записи может содержать часть кадра (тогда он сравнится со следующим куском)
+		// или несколько кадров; все они должны прийти до одного срока
+		_, _ = want.Write(rec.Data)
+		deadline := time.Now().Add(gap + r.Wait)
+		for {
+			exp, _, ok := want.Frame()
+			if !ok {
+				break
+			}
+			got, err := in.next(deadline)
+			switch {
+			case err == nil && bytes.Equal(got, exp):
+				st.Matched++
+				r.logf("replay RX: % X (match)", got)
+			case err == nil:
+				st.Differed++
+				r.logf("replay RX: % X (differs, recorded % X)", got, exp)
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				st.Missing++
+				in.missed = append(in.missed, exp)
+				r.logf("replay RX: timeout (%d byte(s) of an incomplete frame kept), recorded % X", in.dec.Buffered(), exp)
+			default:
+				return st, err
+			}
+		}
+	}
+	return st, nil
+}
+
+// replayInput - входящий поток воспроизводимого соединения. Остаток незавершённого кадра
+// хранится в декодере между кусками записи
+type replayInput struct {
+	r         *Replayer
+	conn      net.Conn
+	dec       *ft12.Decoder
+	discarded uint64
+	missed    [][]byte // кадры, не дождавшиеся своего срока: они могут прийти позже
+}
+
+// next читает следующий кадр до срока deadline. Запоздавший кадр, который совпадает
+// с ранее не дождавшимся, пропускается, чтобы не сдвигать сравнение
+func (in *replayInput) next(deadline time.Time) ([]byte, error) {
+	for {
+		_ = in.conn.SetReadDeadline(deadline)
+		got, _, err := in.dec.Next()
+		_ = in.conn.SetReadDeadline(time.Time{})
+		if n := in.dec.Stats().Discarded; n > in.discarded {
+			in.r.logf("replay RX: %d byte(s) outside frames skipped", n-in.discarded)
+			in.discarded = n
+		}
+		if err != nil {
+			return nil, err
+		}
+		late := -1
+		for i, m := range in.missed {
+			if bytes.Equal(got, m) {
+				late = i
+				break
+			}
+		}
+		if late < 0 {
+			return got, nil
+		}
+		in.missed = append(in.missed[:late], in.missed[late+1:]...)
+		in.r.logf("replay RX: % X (late, recorded earlier)", got)
+	}
+}
+
+func (r *Replayer) scale(d time.Duration) time.Duration {
+	if r.Speed <= 0 || d < 0 {
+		return 0
+	}
+	return time.Duration(float64(d) / r.Speed)
+}
+
+func (r *Replayer) logf(format string, args ...interface{}) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
@@ -0,0 +1,212 @@
+package capture
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"sln/ft12"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustEncode(t *testing.T, f ft12.Frame) []byte {
+	t.Helper()
+	b, err := f.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return b
+}
+
+// Клиент воспроизводит запись перед прибором, который отвечает по сценарию: на i-й
+// запрос прибор пишет куски device[i]. Сравнение идёт по кадрам, а не по кускам
+func TestReplayerPlay(t *testing.T) {
+	req1 := mustEncode(t, ft12.Frame{Control: 0x73, Address: 0x01, Data: []byte{0x01}})
+	req2 := mustEncode(t, ft12.Frame{Control: 0x53, Address: 0x01, Data: []byte{0x02}})
+	resp1 := mustEncode(t, ft12.Frame{Control: 0x08, Address: 0x01, Data: []byte("2026-10-17 13:25:10")})
+	resp2 := mustEncode(t, ft12.Frame{Control: 0x08, Address: 0x01, Data: []byte{0x02, 0x00}})
+	other := mustEncode(t, ft12.Frame{Control: 0x08, Address: 0x01, Data: []byte("2026-10-17 13:25:11")})
+	ack := mustEncode(t, ft12.Frame{Kind: ft12.KindAck})
+
+	tests := []struct {
+		name     string
+		recorded [2][]byte // записанные ответы на req1 и req2
+		device   [2][][]byte
+		want     Stats
+	}{
+		{"same chunks", [2][]byte{resp1, resp2},
+			[2][][]byte{{resp1}, {resp2}}, Stats{Sent: 2, Matched: 2}},
+		{"other fragmentation", [2][]byte{resp1, resp2},
+			[2][][]byte{{resp1[:3], resp1[3:10], resp1[10:]}, {resp2[:1], resp2[1:]}}, Stats{Sent: 2, Matched: 2}},
+		{"garbage before frame", [2][]byte{resp1, resp2},
+			[2][][]byte{{{0xFF, 0x00, 0x68}, resp1}, {resp2}}, Stats{Sent: 2, Matched: 2}},
+		{"differs", [2][]byte{resp1, resp2},
+			[2][][]byte{{other}, {resp2}}, Stats{Sent: 2, Matched: 1, Differed: 1}},
+		{"two frames in one recorded chunk", [2][]byte{append(append([]byte(nil), ack...), resp1...), resp2},
+			[2][][]byte{{ack}, {resp1, resp2}}, Stats{Sent: 2, Matched: 2, Missing: 1}},
+		// Ответ на req1 опоздал: после таймаута он приходит вместе с ответом на req2,
+		// пропускается как запоздавший, и resp2 сравнивается со своей записью
+		{"late frame", [2][]byte{resp1, resp2},
+			[2][][]byte{nil, {resp1, resp2}}, Stats{Sent: 2, Matched: 1, Missing: 1}},
+		// Незаконченный кадр сохраняется между кусками записи
+		{"incomplete frame kept", [2][]byte{resp1, resp2},
+			[2][][]byte{{resp1[:5]}, {resp1[5:], resp2}}, Stats{Sent: 2, Matched: 1, Missing: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t0 := time.Date(2026, time.October, 17, 13, 0, 0, 0, time.UTC)
+			recs := []Record{
+				{Time: t0, Dir: DirTX, Data: req1},
+				{Time: t0.Add(10 * time.Millisecond), Dir: DirRX, Data: tt.recorded[0]},
+				{Time: t0.Add(time.Second), Dir: DirTX, Data: req2},
+				{Time: t0.Add(time.Second + 10*time.Millisecond), Dir: DirRX, Data: tt.recorded[1]},
+			}
+			client, device := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer device.Close()
+				dec := ft12.NewDecoder(device, ft12.Codec{}, 0)
+				for _, chunks := range tt.device {
+					if _, _, err := dec.Next(); err != nil {
+						return
+					}
+					for _, c := range chunks {
+						if _, err := device.Write(c); err != nil {
+							return
+						}
+					}
+				}
+				_, _, _ = dec.Next() // держим соединение до конца воспроизведения
+			}()
+
+			r := &Replayer{Wait: 100 * time.Millisecond, Logf: t.Logf}
+			st, err := r.Play(client, recs, func(rec Record) bool { return rec.Dir == DirTX })
+			if err != nil {
+				t.Fatalf("Play: %v", err)
+			}
+			if st != tt.want {
+				t.Errorf("stats %+v, want %+v", st, tt.want)
+			}
+		})
+	}
+}
+
+// Обрыв соединения прерывает воспроизведение с ошибкой
+func TestReplayerConnectionClosed(t *testing.T) {
+	req := mustEncode(t, ft12.Frame{Control: 0x73, Address: 0x01})
+	resp := mustEncode(t, ft12.Frame{Control: 0x08, Address: 0x01, Data: []byte{0x00}})
+	client, device := net.Pipe()
+	defer client.Close()
+	go func() {
+		buf := make([]byte, 64)
+		_, _ = device.Read(buf)
+		_ = device.Close()
+	}()
+	recs := []Record{{Dir: DirTX, Data: req}, {Dir: DirRX, Data: resp}}
+	r := &Replayer{Wait: time.Second}
+	st, err := r.Play(client, recs, func(rec Record) bool { return rec.Dir == DirTX })
+	if err == nil {
+		t.Fatalf("Play: no error, stats %+v", st)
+	}
+	if st.Sent != 1 {
+		t.Errorf("stats %+v, want 1 sent", st)
+	}
+}
+
+func TestReplayerScale(t *testing.T) {
+	tests := []struct {
+		speed    float64
+		in, want time.Duration
+	}{
+		{1, time.Second, time.Second},
+		{10, time.Second, 100 * time.Millisecond},
+		{0, time.Second, 0},
+		{1, -time.Second, 0},
+	}
+	for _, tt := range tests {
+		r := &Replayer{Speed: tt.speed}
+		if got := r.scale(tt.in); got != tt.want {
+			t.Errorf("speed %v: scale(%s) = %s, want %s", tt.speed, tt.in, got, tt.want)
+		}
+	}
+}
+
+// Запись Recorder читается Load с теми же кусками, а Streams раскладывает их по сторонам
+func TestRecorderLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.jsonl")
+	rec, err := Create(path, SideServer)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	device := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 20), Port: 9000}
+	poller := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 49482}
+	request := []byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}
+	response := []byte{0xE5}
+	c := rec.Wrap(&fakeConn{in: bytes.NewReader(request), local: device, remote: poller})
+	got := make([]byte, len(request))
+	if _, err := c.Read(got); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if _, err := c.Write(response); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	rec.Record("other", DirTX, nil) // пустой кусок не записывается
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"conn":"10.0.0.20:9000->10.0.0.5:49482"`) ||
+		!strings.Contains(string(data), `"data":"68 03 68 73 01 01 75 16"`) {
+		t.Errorf("unexpected file contents:\n%s", data)
+	}
+
+	cp, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cp.Side != SideServer || len(cp.Records) != 2 {
+		t.Fatalf("side %q, %d record(s)", cp.Side, len(cp.Records))
+	}
+	if r := cp.Records[0]; r.Dir != DirRX || cp.FromDevice(r) || !bytes.Equal(r.Data, request) {
+		t.Errorf("record 0: %+v", r)
+	}
+	if r := cp.Records[1]; r.Dir != DirTX || !cp.FromDevice(r) || !bytes.Equal(r.Data, response) {
+		t.Errorf("record 1: %+v", r)
+	}
+	streams := cp.Streams()
+	if len(streams) != 1 || streams[0].Poller != "10.0.0.5:49482" || streams[0].Device != "10.0.0.20:9000" {
+		t.Fatalf("streams %+v", streams)
+	}
+	if chunks := streams[0].Chunks; len(chunks) != 2 || chunks[0].FromDevice || !chunks[1].FromDevice {
+		t.Errorf("chunks %+v", chunks)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	const header = `{"format":"ft12-capture","version":1,"side":"client","started":"2026-10-17T13:00:00Z"}` + "\n"
+	tests := []struct {
+		name, data, want string
+	}{
+		{"empty", "", "empty capture"},
+		{"not a capture", `{"format":"pcap"}`, "not a capture file"},
+		{"unknown side", `{"format":"ft12-capture","side":"proxy"}`, "unknown side"},
+		{"bad header", `{"format":`, "x.jsonl:1"},
+		{"unknown dir", header + `{"t":"2026-10-17T13:00:05Z","conn":"a->b","dir":"up","data":"E5"}`, `unknown dir "up"`},
+		{"bad hex", header + `{"t":"2026-10-17T13:00:05Z","conn":"a->b","dir":"tx","data":"E"}`, "bad hex data"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "x.jsonl")
+		if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Load error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package ft12
+
+import (
+	"errors"
+	"testing"
+)
+
+func signed(t *testing.T, d Dialect, kind ChecksumKind) []byte {
+	t.Helper()
+	b, err := (&Frame{Control: 0x08, Address: 0x01, Data: []byte{0x01, 0x02, 0x03}, ChecksumKind: kind, Dialect: d}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    Codec
+		signedBy ChecksumKind
+		want     ChecksumKind
+		wantErr  *ChecksumError // nil - без ошибки
+	}{
+		{"auto sum", Codec{}, ChecksumSum, ChecksumSum, nil},
+		{"auto detects crc16", Codec{}, ChecksumCRC16, ChecksumCRC16, nil},
+		{"auto configured crc8", Codec{Checksum: ChecksumCRC8}, ChecksumCRC8, ChecksumCRC8, nil},
+		{"auto falls back to sum", Codec{Checksum: ChecksumCRC16CCITT}, ChecksumSum, ChecksumSum, nil},
+		{"strict match", Codec{Checksum: ChecksumCRC16, Strict: true}, ChecksumCRC16, ChecksumCRC16, nil},
+		{"strict reports detected", Codec{Checksum: ChecksumSum, Strict: true}, ChecksumCRC16,
+			"", &ChecksumError{Want: ChecksumSum, Detected: ChecksumCRC16}},
+		{"strict default is sum", Codec{Strict: true}, ChecksumCRC16CCITT,
+			"", &ChecksumError{Want: ChecksumSum, Detected: ChecksumCRC16CCITT}},
+		{"strict ft12 dialect", Codec{Dialect: DialectFT12, Checksum: ChecksumCRC16X25, Strict: true}, ChecksumCRC16X25, ChecksumCRC16X25, nil},
+	}
+	for _, tt := range tests {
+		frame := signed(t, tt.codec.Dialect, tt.signedBy)
+		got, err := tt.codec.VerifyChecksum(frame)
+		if tt.wantErr == nil {
+			if err != nil || got != tt.want {
+				t.Errorf("%s: VerifyChecksum = %q, %v; want %q", tt.name, got, err, tt.want)
+			}
+			continue
+		}
+		var ce *ChecksumError
+		if !errors.As(err, &ce) || *ce != *tt.wantErr {
+			t.Errorf("%s: VerifyChecksum error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("%s: errors.Is(%v, ErrChecksumMismatch) = false", tt.name, err)
+		}
+	}
+}
+
+// Испорченный кадр в строгом режиме не сходится ни с одним алгоритмом: Detected пуст
+func TestVerifyChecksumStrictNoMatch(t *testing.T) {
+	frame := signed(t, DialectSimplified, ChecksumSum)
+	CorruptChecksum(frame, string(ChecksumSum))
+	_, err := Codec{Strict: true}.VerifyChecksum(frame)
+	var ce *ChecksumError
+	if !errors.As(err, &ce) || ce.Want != ChecksumSum || ce.Detected != "" {
+		t.Fatalf("VerifyChecksum = %v, want mismatch without detected algorithm", err)
+	}
+	if _, err := (Codec{}).VerifyChecksum(frame); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("non-strict VerifyChecksum = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+// Нестрогий режим не перебирает весь реестр: кадр, подписанный алгоритмом не из
+// {настроенный, sum, crc16}, отклоняется
+func TestVerifyChecksumNoRegistryScan(t *testing.T) {
+	frame := signed(t, DialectSimplified, ChecksumXOR8)
+	if _, err := (Codec{Checksum: ChecksumCRC16}).VerifyChecksum(frame); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("xor8 frame with crc16 configured: %v, want ErrChecksumMismatch", err)
+	}
+}
+
+// Кадры с испорченной контрольной суммой (как от -badcrc) нестрогий кодек по умолчанию
+// отклоняет: ни один из пробуемых алгоритмов не сходится случайно
+func TestCorruptedFramesRejected(t *testing.T) {
+	for _, kind := range []ChecksumKind{ChecksumSum, ChecksumCRC16} {
+		accepted := 0
+		for i := 0; i < 20000; i++ {
+			data := []byte{0x02, byte(i), byte(i >> 8), byte(i * 7)}
+			b, err := (&Frame{Control: 0x08, Address: 0x01, Data: data, ChecksumKind: kind}).Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			CorruptChecksum(b, string(kind))
+			if _, err := (Codec{}).Decode(b); err == nil {
+				accepted++
+			}
+		}
+		if accepted != 0 {
+			t.Errorf("%s: %d of 20000 corrupted frame(s) accepted", kind, accepted)
+		}
+	}
+}
+
+// Короткий кадр всегда подписан суммой, в том числе в строгом режиме с другим алгоритмом
+func TestVerifyChecksumShortFrame(t *testing.T) {
+	kind, err := Codec{Checksum: ChecksumCRC16, Strict: true}.VerifyChecksum(BuildShort(0x49, 0x01))
+	if err != nil || kind != ChecksumSum {
+		t.Fatalf("VerifyChecksum(short) = %q, %v; want sum", kind, err)
+	}
+}
+
+// В строгом режиме 0x16 внутри CRC не обрезает кадр: позиция терминатора берётся по ширине
+// настроенного алгоритма
+func TestExtractStrictEndByteInCRC(t *testing.T) {
+	c := Codec{Checksum: ChecksumCRC16, Strict: true}
+	for data := 0; data < 0x10000; data++ {
+		skel, _ := c.BuildSkeleton(0x08, 0x01, []byte{byte(data), byte(data >> 8)})
+		frame := c.AppendChecksum(skel, string(ChecksumCRC16))
+		if frame[len(frame)-3] != 0x16 {
+			continue
+		}
+		n, kind, st := c.frameAt(frame)
+		if st != frameComplete || kind != KindVariable || n != len(frame) {
+			t.Fatalf("frameAt(% X) = %d, %s, %d; want whole frame", frame, n, kind, st)
+		}
+		return
+	}
+	t.Fatal("no two-byte DATA gives 0x16 in the first CRC byte")
+}
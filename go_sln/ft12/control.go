@@ -0,0 +1,159 @@
+package ft12
+
+import "fmt"
+
+// Биты поля CONTROL (IEC 60870-5-2)
+const (
+	CtrlDIR = 0x80 // направление (в проекте - признак ответа)
+	CtrlPRM = 0x40 // 1 - сообщение от первичной (ведущей) станции
+	CtrlFCB = 0x20 // бит счёта кадров (первичная станция)
+	CtrlFCV = 0x10 // бит достоверности FCB (первичная станция)
+	CtrlACD = 0x20 // запрос доступа к данным класса 1 (вторичная станция)
+	CtrlDFC = 0x10 // контроль потока данных: буфер переполнен (вторичная станция)
+
+	ctrlFuncMask = 0x0F
+)
+
+// Функции первичной станции (PRM = 1)
+const (
+	FuncResetLink      = 0  // сброс удалённого канала
+	FuncResetProcess   = 1  // сброс пользовательского процесса
+	FuncUserData       = 3  // передача данных с подтверждением
+	FuncUserDataNoResp = 4  // передача данных без ответа
+	FuncAccessDemand   = 8  // запрос на доступ
+	FuncLinkStatus     = 9  // запрос состояния канала
+	FuncClass1         = 10 // запрос данных класса 1
+	FuncClass2         = 11 // запрос данных класса 2
+)
+
+// Функции вторичной станции (PRM = 0)
+const (
+	FuncAck            = 0  // положительное подтверждение
+	FuncNack           = 1  // сообщение не принято, канал занят
+	FuncRespUserData   = 8  // ответ с данными
+	FuncRespNoData     = 9  // NACK: запрошенных данных нет
+	FuncRespLinkStatus = 11 // состояние канала
+	FuncLinkNotWorking = 14 // служба канала не работает
+	FuncLinkNotImpl    = 15 // служба канала не реализована
+)
+
+var primaryFuncNames = map[byte]string{
+	FuncResetLink:      "reset remote link",
+	FuncResetProcess:   "reset user process",
+	FuncUserData:       "user data, confirm expected",
+	FuncUserDataNoResp: "user data, no reply",
+	FuncAccessDemand:   "request access demand",
+	FuncLinkStatus:     "request link status",
+	FuncClass1:         "request class 1 data",
+	FuncClass2:         "request class 2 data",
+}
+
+var secondaryFuncNames = map[byte]string{
+	FuncAck:            "ACK",
+	FuncNack:           "NACK, message not accepted",
+	FuncRespUserData:   "user data",
+	FuncRespNoData:     "NACK, no data available",
+	FuncRespLinkStatus: "link status",
+	FuncLinkNotWorking: "link service not functioning",
+	FuncLinkNotImpl:    "link service not implemented",
+}
+
+// ControlField - разобранный байт CONTROL.
+// FCB/FCV имеют смысл для первичной станции, ACD/DFC - для вторичной (это одни и те же биты)
+type ControlField struct {
+	DIR  bool
+	PRM  bool
+	FCB  bool
+	FCV  bool
+	ACD  bool
+	DFC  bool
+	Func byte
+}
+
+// ParseControl разбирает байт CONTROL
+func ParseControl(b byte) ControlField {
+	c := ControlField{
+		DIR:  b&CtrlDIR != 0,
+		PRM:  b&CtrlPRM != 0,
+		Func: b & ctrlFuncMask,
+	}
+	if c.PRM {
+		c.FCB = b&CtrlFCB != 0
+		c.FCV = b&CtrlFCV != 0
+	} else {
+		c.ACD = b&CtrlACD != 0
+		c.DFC = b&CtrlDFC != 0
+	}
+	return c
+}
+
+// Byte собирает байт CONTROL
+func (c ControlField) Byte() byte {
+	b := c.Func & ctrlFuncMask
+	if c.DIR {
+		b |= CtrlDIR
+	}
+	if c.PRM {
+		b |= CtrlPRM
+		if c.FCB {
+			b |= CtrlFCB
+		}
+		if c.FCV {
+			b |= CtrlFCV
+		}
+	} else {
+		if c.ACD {
+			b |= CtrlACD
+		}
+		if c.DFC {
+			b |= CtrlDFC
+		}
+	}
+	return b
+}
+
+// FuncName возвращает название функции с учётом направления
+func (c ControlField) FuncName() string {
+	names := secondaryFuncNames
+	if c.PRM {
+		names = primaryFuncNames
+	}
+	if n, ok := names[c.Func]; ok {
+		return n
+	}
+	return "reserved"
+}
+
+// IsConfirm - положительное подтверждение вторичной станции
+func (c ControlField) IsConfirm() bool { return !c.PRM && c.Func == FuncAck }
+
+// IsNack - отрицательное подтверждение вторичной станции
+func (c ControlField) IsNack() bool {
+	return !c.PRM && (c.Func == FuncNack || c.Func == FuncRespNoData)
+}
+
+// HasData - ответ вторичной станции с данными пользователя
+func (c ControlField) HasData() bool { return !c.PRM && c.Func == FuncRespUserData }
+
+// String - описание для логов, например "0x73 PRM FCB=1 FCV=1 FC3 user data, confirm expected"
+func (c ControlField) String() string {
+	if c.PRM {
+		return fmt.Sprintf("0x%02X PRM FCB=%d FCV=%d FC%d %s", c.Byte(), b2i(c.FCB), b2i(c.FCV), c.Func, c.FuncName())
+	}
+	return fmt.Sprintf("0x%02X SEC ACD=%d DFC=%d FC%d %s", c.Byte(), b2i(c.ACD), b2i(c.DFC), c.Func, c.FuncName())
+}
+
+// Describe - краткое описание кадра для логов рядом с hex-дампом
+func Describe(f *Frame) string {
+	if f.Kind == KindAck {
+		return "ack E5"
+	}
+	return fmt.Sprintf("%s ctrl=[%s] addr=0x%02X data=%d", f.Kind, ParseControl(f.Control), f.Address, len(f.Data))
+}
+
+func b2i(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
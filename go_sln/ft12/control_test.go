@@ -0,0 +1,99 @@
+package ft12
+
+import "testing"
+
+func TestParseControl(t *testing.T) {
+	tests := []struct {
+		b    byte
+		want ControlField
+		name string
+	}{
+		{0x40, ControlField{PRM: true, Func: FuncResetLink}, "reset remote link"},
+		{0x43, ControlField{PRM: true, Func: FuncUserData}, "user data, confirm expected"},
+		{0x53, ControlField{PRM: true, FCV: true, Func: FuncUserData}, "user data, confirm expected"},
+		{0x73, ControlField{PRM: true, FCB: true, FCV: true, Func: FuncUserData}, "user data, confirm expected"},
+		{0x49, ControlField{PRM: true, Func: FuncLinkStatus}, "request link status"},
+		{0x4D, ControlField{PRM: true, Func: 13}, "reserved"},
+		{0x00, ControlField{Func: FuncAck}, "ACK"},
+		{0x01, ControlField{Func: FuncNack}, "NACK, message not accepted"},
+		{0x08, ControlField{Func: FuncRespUserData}, "user data"},
+		{0x88, ControlField{DIR: true, Func: FuncRespUserData}, "user data"},
+		{0x29, ControlField{ACD: true, Func: FuncRespNoData}, "NACK, no data available"},
+		{0x1B, ControlField{DFC: true, Func: FuncRespLinkStatus}, "link status"},
+		{0x3F, ControlField{ACD: true, DFC: true, Func: FuncLinkNotImpl}, "link service not implemented"},
+	}
+	for _, tt := range tests {
+		got := ParseControl(tt.b)
+		if got != tt.want {
+			t.Errorf("ParseControl(0x%02X) = %+v, want %+v", tt.b, got, tt.want)
+		}
+		if got.FuncName() != tt.name {
+			t.Errorf("0x%02X: FuncName = %q, want %q", tt.b, got.FuncName(), tt.name)
+		}
+		if b := tt.want.Byte(); b != tt.b {
+			t.Errorf("%+v: Byte = 0x%02X, want 0x%02X", tt.want, b, tt.b)
+		}
+	}
+}
+
+// Любой байт CONTROL собирается обратно без потерь: биты 5 и 4 читаются как FCB/FCV
+// или ACD/DFC в зависимости от PRM
+func TestControlRoundTrip(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		c := ParseControl(byte(i))
+		if c.Byte() != byte(i) {
+			t.Errorf("0x%02X: ParseControl = %+v, Byte = 0x%02X", i, c, c.Byte())
+		}
+		if c.PRM && (c.ACD || c.DFC) || !c.PRM && (c.FCB || c.FCV) {
+			t.Errorf("0x%02X: bits of the other station set: %+v", i, c)
+		}
+	}
+	// Func шире 4 бит обрезается, биты чужой станции не попадают в байт
+	if b := (ControlField{PRM: true, ACD: true, Func: 0x13}).Byte(); b != 0x43 {
+		t.Errorf("Byte = 0x%02X, want 0x43", b)
+	}
+	if b := (ControlField{FCB: true, FCV: true, Func: FuncAck}).Byte(); b != 0x00 {
+		t.Errorf("Byte = 0x%02X, want 0x00", b)
+	}
+}
+
+func TestControlPredicates(t *testing.T) {
+	tests := []struct {
+		b                      byte
+		confirm, nack, hasData bool
+	}{
+		{0x00, true, false, false},
+		{0x20, true, false, false}, // ACK с ACD
+		{0x01, false, true, false},
+		{0x09, false, true, false},
+		{0x08, false, false, true},
+		{0x88, false, false, true},
+		{0x0B, false, false, false},
+		{0x40, false, false, false}, // FC 0 первичной станции - не ACK
+		{0x41, false, false, false},
+		{0x48, false, false, false},
+	}
+	for _, tt := range tests {
+		c := ParseControl(tt.b)
+		if c.IsConfirm() != tt.confirm || c.IsNack() != tt.nack || c.HasData() != tt.hasData {
+			t.Errorf("0x%02X: confirm %v nack %v data %v, want %v %v %v",
+				tt.b, c.IsConfirm(), c.IsNack(), c.HasData(), tt.confirm, tt.nack, tt.hasData)
+		}
+	}
+}
+
+func TestControlString(t *testing.T) {
+	tests := []struct {
+		b    byte
+		want string
+	}{
+		{0x73, "0x73 PRM FCB=1 FCV=1 FC3 user data, confirm expected"},
+		{0x40, "0x40 PRM FCB=0 FCV=0 FC0 reset remote link"},
+		{0x2B, "0x2B SEC ACD=1 DFC=0 FC11 link status"},
+	}
+	for _, tt := range tests {
+		if got := ParseControl(tt.b).String(); got != tt.want {
+			t.Errorf("0x%02X: String = %q, want %q", tt.b, got, tt.want)
+		}
+	}
+}
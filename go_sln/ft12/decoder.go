@@ -0,0 +1,116 @@
+package ft12
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxBuffer - ограничение буфера потокового декодера по умолчанию
+const DefaultMaxBuffer = 4096
+
+// Stats - счётчики потокового декодера
+type Stats struct {
+	Frames    uint64 // извлечено кадров
+	Discarded uint64 // отброшено байт (мусор, ложные старты, переполнение)
+	Resyncs   uint64 // ложных стартовых байт, после которых поиск продолжен со следующего байта
+	Oversize  uint64 // незавершённых кадров, не поместившихся в буфер
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("frames=%d discarded=%d resyncs=%d oversize=%d",
+		s.Frames, s.Discarded, s.Resyncs, s.Oversize)
+}
+
+// Decoder - потоковый декодер кадров поверх io.Reader.
+// Пересинхронизируется внутри одного вызова Next и держит буфер не больше maxBuf
+type Decoder struct {
+	r      io.Reader
+	codec  Codec
+	buf    bytes.Buffer
+	tmp    []byte
+	maxBuf int
+	err    error
+	stats  Stats
+}
+
+// NewDecoder создаёт декодер; maxBuf <= 0 означает DefaultMaxBuffer
+func NewDecoder(r io.Reader, codec Codec, maxBuf int) *Decoder {
+	if maxBuf <= 0 {
+		maxBuf = DefaultMaxBuffer
+	}
+	return &Decoder{
+		r:      r,
+		codec:  codec,
+		tmp:    make([]byte, 4096),
+		maxBuf: maxBuf,
+	}
+}
+
+// Next возвращает следующий полный кадр и его вид, при необходимости читая из r.
+// Ошибка чтения возвращается после того, как все полные кадры из буфера выданы
+func (d *Decoder) Next() ([]byte, Kind, error) {
+	for {
+		if frame, kind, ok := d.extract(); ok {
+			return frame, kind, nil
+		}
+		if d.err != nil {
+			err := d.err
+			d.err = nil
+			return nil, 0, err
+		}
+		n, err := d.r.Read(d.tmp)
+		if n > 0 {
+			d.buf.Write(d.tmp[:n])
+		}
+		if err != nil {
+			d.err = err
+		}
+	}
+}
+
+// Write добавляет байты в буфер, не читая r. Так декодер разбирает поток, который
+// читает вызывающий (например, копию пересылаемых байтов); кадры выдаёт Frame
+func (d *Decoder) Write(p []byte) (int, error) { return d.buf.Write(p) }
+
+// Frame возвращает следующий полный кадр из уже накопленных байтов, не читая r.
+// ok = false - полного кадра в буфере нет
+func (d *Decoder) Frame() (frame []byte, kind Kind, ok bool) { return d.extract() }
+
+// Stats возвращает текущие значения счётчиков
+func (d *Decoder) Stats() Stats { return d.stats }
+
+// Reset отбрасывает накопленные байты (например, запоздавший ответ перед новым запросом)
+// и возвращает их количество
+func (d *Decoder) Reset() int {
+	n := d.buf.Len()
+	d.stats.Discarded += uint64(n)
+	d.buf.Reset()
+	return n
+}
+
+// Buffered возвращает число байт, ожидающих в буфере
+func (d *Decoder) Buffered() int { return d.buf.Len() }
+
+// extract извлекает кадр из буфера, отбрасывая мусор и ограничивая размер буфера
+func (d *Decoder) extract() ([]byte, Kind, bool) {
+	for {
+		skip, n, kind, resyncs := d.codec.scan(d.buf.Bytes())
+		d.stats.Resyncs += resyncs
+		d.stats.Discarded += uint64(skip)
+		d.buf.Next(skip)
+		if n > 0 {
+			frame := make([]byte, n)
+			copy(frame, d.buf.Next(n))
+			d.stats.Frames++
+			return frame, kind, true
+		}
+		if d.buf.Len() <= d.maxBuf {
+			return nil, 0, false
+		}
+		// Незавершённый кадр не помещается в буфер: считаем старт ложным и ищем дальше
+		d.stats.Oversize++
+		d.stats.Discarded++
+		d.buf.Next(1)
+	}
+}
@@ -0,0 +1,126 @@
+package ft12
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+var (
+	readTime = []byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}
+	linkReq  = []byte{0x10, 0x49, 0x01, 0x4A, 0x16}
+)
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// Кадры, приходящие по одному байту, собираются; после последнего кадра возвращается ошибка чтения
+func TestDecoderNextByteByByte(t *testing.T) {
+	stream := concat(readTime, linkReq, []byte{SingleCharAck}, readTime)
+	dec := NewDecoder(iotest.OneByteReader(bytes.NewReader(stream)), Codec{}, 0)
+	want := [][]byte{readTime, linkReq, {SingleCharAck}, readTime}
+	for i, w := range want {
+		frame, _, err := dec.Next()
+		if err != nil || !bytes.Equal(frame, w) {
+			t.Fatalf("frame %d = % X, %v; want % X", i, frame, err, w)
+		}
+	}
+	if _, _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Next after last frame: %v, want EOF", err)
+	}
+	if st := dec.Stats(); st != (Stats{Frames: 4}) {
+		t.Errorf("Stats = %s", st)
+	}
+}
+
+// Ошибка чтения вместе с данными: сначала выдаются все полные кадры
+func TestDecoderErrorAfterFrames(t *testing.T) {
+	boom := errors.New("boom")
+	r := iotest.DataErrReader(io.MultiReader(bytes.NewReader(concat(readTime, readTime)), iotest.ErrReader(boom)))
+	dec := NewDecoder(r, Codec{}, 0)
+	for i := 0; i < 2; i++ {
+		if _, _, err := dec.Next(); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+	}
+	if _, _, err := dec.Next(); !errors.Is(err, boom) {
+		t.Fatalf("Next = %v, want %v", err, boom)
+	}
+}
+
+func TestDecoderResync(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxBuf int
+		in     []byte
+		want   Stats
+	}{
+		{"clean", 0, concat(readTime, linkReq), Stats{Frames: 2}},
+		{"leading garbage", 0, concat([]byte{0x00, 0xFF, 0x42}, readTime), Stats{Frames: 1, Discarded: 3}},
+		// 0x68 с неверным вторым 0x68 - ложный старт, поиск продолжается со следующего байта
+		{"false variable start", 0, concat([]byte{0x68, 0x05, 0x00}, readTime), Stats{Frames: 1, Discarded: 3, Resyncs: 1}},
+		// 0x10 без 0x16 на пятой позиции - ложный старт короткого кадра
+		{"false short start", 0, concat([]byte{0x10, 0x00, 0x00, 0x00, 0x00}, readTime), Stats{Frames: 1, Discarded: 5, Resyncs: 1}},
+		// Кадр переменной длины с ошибочным концом: все позиции 0x16 в буфере, но терминатора нет
+		{"missing end byte", 0, concat(readTime[:7], []byte{0x00, 0x00, 0x00}, readTime), Stats{Frames: 1, Discarded: 10, Resyncs: 2}},
+		// Заголовок обещает 255 байт, буфер 16: незавершённый кадр отбрасывается и поток
+		// синхронизируется по следующему кадру
+		{"oversize", 16, concat([]byte{0x68, 0xFF, 0x68}, make([]byte, 20), readTime),
+			Stats{Frames: 1, Discarded: 23, Resyncs: 1, Oversize: 1}},
+	}
+	for _, tt := range tests {
+		dec := NewDecoder(nil, Codec{}, tt.maxBuf)
+		_, _ = dec.Write(tt.in)
+		for {
+			if _, _, ok := dec.Frame(); !ok {
+				break
+			}
+		}
+		if got := dec.Stats(); got != tt.want {
+			t.Errorf("%s: Stats = %s, want %s", tt.name, got, tt.want)
+		}
+		if dec.Buffered() != 0 {
+			t.Errorf("%s: %d byte(s) left in buffer", tt.name, dec.Buffered())
+		}
+	}
+}
+
+// Буфер не растёт выше maxBuf, даже если данные приходят порциями без единого кадра
+func TestDecoderBufferBound(t *testing.T) {
+	const maxBuf = 64
+	dec := NewDecoder(nil, Codec{}, maxBuf)
+	for i := 0; i < 100; i++ {
+		_, _ = dec.Write([]byte{0x68, 0xFF, 0x68, 0x01, 0x02, 0x03})
+		if _, _, ok := dec.Frame(); ok {
+			t.Fatal("unexpected frame")
+		}
+		if dec.Buffered() > maxBuf {
+			t.Fatalf("buffer %d bytes, limit %d", dec.Buffered(), maxBuf)
+		}
+	}
+	if dec.Stats().Oversize == 0 {
+		t.Errorf("Oversize = 0, want > 0")
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	dec := NewDecoder(nil, Codec{}, 0)
+	_, _ = dec.Write(readTime[:5])
+	if n := dec.Reset(); n != 5 {
+		t.Fatalf("Reset = %d, want 5", n)
+	}
+	if dec.Buffered() != 0 || dec.Stats().Discarded != 5 {
+		t.Fatalf("after Reset: buffered %d, stats %s", dec.Buffered(), dec.Stats())
+	}
+	_, _ = dec.Write(readTime)
+	if frame, kind, ok := dec.Frame(); !ok || kind != KindVariable || !bytes.Equal(frame, readTime) {
+		t.Fatalf("Frame after Reset = % X, %s, %v", frame, kind, ok)
+	}
+}
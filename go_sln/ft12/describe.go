@@ -0,0 +1,114 @@
+package ft12
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Коды команд прикладного уровня TTR20
+var commandNames = map[byte]string{
+	0x01: "read-time",
+	0x02: "read-archive",
+	0x03: "write-time",
+	0x04: "read-param",
+	0x05: "write-param",
+}
+
+// Annotator поясняет кадры для логов и разбора захватов (ft12dump, прокси эмулятора)
+type Annotator struct {
+	Codec     Codec
+	TimeCodec TimeCodec
+}
+
+// Describe - описание кадра: вид, CONTROL, адрес, команда, время и статус контрольной суммы.
+// fromDevice - кадр от прибора (от этого зависит разбор DATA).
+// Кадр с неверной суммой всё равно разбирается по структуре; bad - сумма не сошлась
+func (a Annotator) Describe(frame []byte, kind Kind, fromDevice bool) (line string, bad bool) {
+	if kind == KindAck {
+		return "ack E5", false
+	}
+	sum, err := a.Codec.VerifyChecksum(frame)
+	status := checksumStatus(sum, a.Codec.Checksum, err)
+
+	var ctrl, addr byte
+	var data []byte
+	if kind == KindShort {
+		ctrl, addr = frame[1], frame[2]
+	} else {
+		hdr := a.Codec.Dialect.HeaderLen()
+		ctrl, addr = frame[hdr], frame[hdr+1]
+		data = a.Codec.PayloadData(frame)
+	}
+	parts := []string{fmt.Sprintf("%s ctrl=[%s] addr=0x%02X", kind, ParseControl(ctrl), addr)}
+	if kind == KindVariable {
+		parts = append(parts, a.command(data, fromDevice))
+	}
+	parts = append(parts, status)
+	return strings.Join(parts, " "), err != nil
+}
+
+// command поясняет DATA: код команды, отрицательный ответ, время, сегмент архива
+func (a Annotator) command(data []byte, fromDevice bool) string {
+	if len(data) == 0 {
+		return "no data"
+	}
+	if de, ok := ParseError(data); ok {
+		return fmt.Sprintf("cmd=0x%02X %s ERROR %s", de.Cmd, CommandName(de.Cmd), de.Code)
+	}
+	cmd := data[0]
+	s := fmt.Sprintf("cmd=0x%02X %s", cmd, CommandName(cmd))
+	switch {
+	case cmd == 0x01 && fromDevice, cmd == 0x03 && !fromDevice:
+		dt, err := a.TimeCodec.Decode(data[1:])
+		if err != nil {
+			return s + fmt.Sprintf(" time=? (%v)", err)
+		}
+		s += " time=" + dt.Time.Format("2006-01-02 15:04:05.000")
+		if dt.Invalid {
+			s += " invalid"
+		}
+		if dt.Summer {
+			s += " summer"
+		}
+	case cmd == 0x02 && fromDevice && len(data) > 1:
+		seg := data[1]
+		s += fmt.Sprintf(" seg=%d", seg&SegmentSeqMask)
+		if seg&SegmentFIR != 0 {
+			s += " FIR"
+		}
+		if seg&SegmentFIN != 0 {
+			s += " FIN"
+		}
+		s += fmt.Sprintf(" %d byte(s)", len(data)-2)
+	case (cmd == 0x04 || cmd == 0x05) && len(data) >= 3:
+		s += fmt.Sprintf(" reg=%d", uint16(data[1])|uint16(data[2])<<8)
+	}
+	return s
+}
+
+// CommandName - имя команды TTR20 ("read-time", ...) или "unknown"
+func CommandName(cmd byte) string {
+	if name, ok := commandNames[cmd]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// checksumStatus - "checksum sum ok", "checksum crc16 ok (expected sum)" или "BAD CHECKSUM (...)"
+func checksumStatus(got, want ChecksumKind, err error) string {
+	if err != nil {
+		var ce *ChecksumError
+		if errors.As(err, &ce) || errors.Is(err, ErrChecksumMismatch) {
+			return fmt.Sprintf("BAD CHECKSUM (%v)", err)
+		}
+		return fmt.Sprintf("BAD FRAME (%v)", err)
+	}
+	if want == "" {
+		want = ChecksumSum
+	}
+	if got != want {
+		return fmt.Sprintf("checksum %s ok (expected %s)", got, want)
+	}
+	return fmt.Sprintf("checksum %s ok", got)
+}
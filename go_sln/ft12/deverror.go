@@ -0,0 +1,74 @@
+package ft12
+
+import "fmt"
+
+// Отрицательный ответ прибора - кадр с данными, где у кода команды выставлен старший бит:
+//
+//	DATA = [CMD | 0x80] [КОД ОШИБКИ]
+//
+// Коды команд прикладного уровня меньше 0x80, поэтому ответ однозначно отличается от успешного
+const ErrorFlag = 0x80
+
+// ErrorCode - код ошибки в отрицательном ответе
+type ErrorCode byte
+
+const (
+	ErrCodeUnknownCommand ErrorCode = 0x01 // команда не поддерживается
+	ErrCodeBadLength      ErrorCode = 0x02 // неверная длина или формат данных запроса
+	ErrCodeAccessDenied   ErrorCode = 0x03 // нет прав на чтение/запись
+	ErrCodeBusy           ErrorCode = 0x04 // прибор занят, повторить позже
+	ErrCodeBadAddress     ErrorCode = 0x05 // нет такого регистра/объекта
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrCodeUnknownCommand:
+		return "unknown command"
+	case ErrCodeBadLength:
+		return "bad length"
+	case ErrCodeAccessDenied:
+		return "access denied"
+	case ErrCodeBusy:
+		return "busy"
+	case ErrCodeBadAddress:
+		return "bad address"
+	}
+	return fmt.Sprintf("error 0x%02X", byte(c))
+}
+
+// DeviceError - отрицательный ответ прибора на команду Cmd.
+// errors.Is сравнивает только код, поэтому подходят ErrUnknownCommand и другие образцы ниже
+type DeviceError struct {
+	Cmd  byte
+	Code ErrorCode
+}
+
+var (
+	ErrUnknownCommand = &DeviceError{Code: ErrCodeUnknownCommand}
+	ErrBadLength      = &DeviceError{Code: ErrCodeBadLength}
+	ErrAccessDenied   = &DeviceError{Code: ErrCodeAccessDenied}
+	ErrBusy           = &DeviceError{Code: ErrCodeBusy}
+	ErrBadAddress     = &DeviceError{Code: ErrCodeBadAddress}
+)
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("device error on cmd 0x%02X: %s", e.Cmd, e.Code)
+}
+
+func (e *DeviceError) Is(target error) bool {
+	t, ok := target.(*DeviceError)
+	return ok && t.Code == e.Code
+}
+
+// ErrorPayload формирует DATA отрицательного ответа на команду cmd
+func ErrorPayload(cmd byte, code ErrorCode) []byte {
+	return []byte{cmd | ErrorFlag, byte(code)}
+}
+
+// ParseError распознаёт DATA отрицательного ответа
+func ParseError(data []byte) (*DeviceError, bool) {
+	if len(data) != 2 || data[0]&ErrorFlag == 0 {
+		return nil, false
+	}
+	return &DeviceError{Cmd: data[0] &^ ErrorFlag, Code: ErrorCode(data[1])}, true
+}
@@ -0,0 +1,89 @@
+package ft12
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want *DeviceError // nil - не отрицательный ответ
+	}{
+		{"unknown command", []byte{0x87, 0x01}, &DeviceError{Cmd: 0x07, Code: ErrCodeUnknownCommand}},
+		{"busy on set-time", []byte{0x83, 0x04}, &DeviceError{Cmd: 0x03, Code: ErrCodeBusy}},
+		{"unlisted code", []byte{0x85, 0x7F}, &DeviceError{Cmd: 0x05, Code: 0x7F}},
+		{"empty", nil, nil},
+		{"flag only", []byte{0x81}, nil},
+		{"too long", []byte{0x81, 0x01, 0x00}, nil},
+		{"no error flag", []byte{0x01, 0x01}, nil},
+		{"time payload", []byte("2026-10-17 13:25:10"), nil},
+	}
+	for _, tt := range tests {
+		got, ok := ParseError(tt.data)
+		if tt.want == nil {
+			if ok {
+				t.Errorf("%s: ParseError(% X) = %+v, want no error response", tt.name, tt.data, got)
+			}
+			continue
+		}
+		if !ok || *got != *tt.want {
+			t.Errorf("%s: ParseError(% X) = %+v, %v; want %+v", tt.name, tt.data, got, ok, tt.want)
+		}
+	}
+}
+
+// ErrorPayload и ParseError - взаимно обратные
+func TestErrorPayloadRoundTrip(t *testing.T) {
+	for _, cmd := range []byte{0x00, 0x01, 0x05, 0x7F} {
+		for _, code := range []ErrorCode{ErrCodeUnknownCommand, ErrCodeBadLength, ErrCodeAccessDenied, ErrCodeBusy, ErrCodeBadAddress} {
+			data := ErrorPayload(cmd, code)
+			if want := []byte{cmd | 0x80, byte(code)}; !bytes.Equal(data, want) {
+				t.Fatalf("ErrorPayload(0x%02X, %s) = % X, want % X", cmd, code, data, want)
+			}
+			de, ok := ParseError(data)
+			if !ok || de.Cmd != cmd || de.Code != code {
+				t.Errorf("ParseError(% X) = %+v, %v", data, de, ok)
+			}
+		}
+	}
+}
+
+// errors.Is сравнивает только код ошибки, в том числе через обёртку
+func TestDeviceErrorIs(t *testing.T) {
+	tests := []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{&DeviceError{Cmd: 0x07, Code: ErrCodeUnknownCommand}, ErrUnknownCommand, true},
+		{&DeviceError{Cmd: 0x03, Code: ErrCodeBusy}, ErrBusy, true},
+		{fmt.Errorf("set time: %w", &DeviceError{Cmd: 0x03, Code: ErrCodeAccessDenied}), ErrAccessDenied, true},
+		{&DeviceError{Cmd: 0x04, Code: ErrCodeBadAddress}, ErrBadLength, false},
+		{&DeviceError{Cmd: 0x04, Code: ErrCodeBadAddress}, ErrChecksumMismatch, false},
+		{ErrChecksumMismatch, ErrUnknownCommand, false},
+	}
+	for _, tt := range tests {
+		if got := errors.Is(tt.err, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceErrorString(t *testing.T) {
+	tests := []struct {
+		err  *DeviceError
+		want string
+	}{
+		{&DeviceError{Cmd: 0x07, Code: ErrCodeUnknownCommand}, "device error on cmd 0x07: unknown command"},
+		{&DeviceError{Cmd: 0x05, Code: 0x7F}, "device error on cmd 0x05: error 0x7F"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package ft12
+
+import "fmt"
+
+// Dialect задаёт вариант заголовка кадра переменной длины
+type Dialect string
+
+const (
+	// DialectSimplified - упрощённый заголовок проекта: 0x68 | LEN | 0x68
+	DialectSimplified Dialect = "simplified"
+	// DialectFT12 - заголовок IEC 60870-5-1 FT1.2: 0x68 | LEN | LEN | 0x68
+	DialectFT12 Dialect = "ft12"
+)
+
+// ParseDialect проверяет имя диалекта из конфигурации
+func ParseDialect(s string) (Dialect, error) {
+	switch Dialect(s) {
+	case DialectSimplified, DialectFT12:
+		return Dialect(s), nil
+	}
+	return "", fmt.Errorf("unknown frame dialect %q (want simplified | ft12)", s)
+}
+
+// HeaderLen возвращает длину заголовка до поля CONTROL.
+// Пустой диалект считается упрощённым
+func (d Dialect) HeaderLen() int {
+	if d == DialectFT12 {
+		return 4
+	}
+	return 3
+}
+
+// header формирует заголовок кадра для заданного LEN
+func (d Dialect) header(lenByte byte) []byte {
+	if d == DialectFT12 {
+		return []byte{0x68, lenByte, lenByte, 0x68}
+	}
+	return []byte{0x68, lenByte, 0x68}
+}
+
+// checkHeader проверяет заголовок в b (b начинается с 0x68 и содержит не меньше HeaderLen байт)
+func (d Dialect) checkHeader(b []byte) bool {
+	if d == DialectFT12 {
+		return b[0] == 0x68 && b[1] == b[2] && b[3] == 0x68
+	}
+	return b[0] == 0x68 && b[2] == 0x68
+}
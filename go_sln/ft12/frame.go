@@ -0,0 +1,278 @@
+// Package ft12 реализует кодек кадров FT1.2, общий для клиента и эмулятора.
+//
+// Формат кадра (упрощённый диалект): 0x68 | LEN | 0x68 | CONTROL | ADDR | DATA... | CHECKSUM | 0x16
+// Формат кадра (диалект ft12):       0x68 | LEN | LEN | 0x68 | CONTROL | ADDR | DATA... | CHECKSUM | 0x16
+// Короткий кадр фиксированной длины: 0x10 | CONTROL | ADDR | CHECKSUM | 0x16
+// Односимвольное подтверждение:      0xE5
+package ft12
+
+import (
+	"bytes"
+)
+
+// MaxDataLen - максимальная длина DATA в одном кадре (LEN = 2 + len(DATA) <= 255)
+const MaxDataLen = 255 - 2
+
+// Kind - вид кадра FT1.2
+type Kind byte
+
+const (
+	KindVariable Kind = iota // кадр переменной длины 0x68 ... 0x16
+	KindShort                // короткий кадр 0x10 C A CS 0x16
+	KindAck                  // односимвольное подтверждение 0xE5
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindVariable:
+		return "variable"
+	case KindShort:
+		return "short"
+	case KindAck:
+		return "ack"
+	}
+	return "unknown"
+}
+
+// Frame - разобранный кадр FT1.2
+type Frame struct {
+	Kind         Kind
+	Control      byte
+	Address      byte
+	Data         []byte
+	ChecksumKind ChecksumKind
+	Dialect      Dialect
+}
+
+// Encode собирает кадр в байты с контрольной суммой и терминатором 0x16
+func (f *Frame) Encode() ([]byte, error) {
+	switch f.Kind {
+	case KindAck:
+		return []byte{SingleCharAck}, nil
+	case KindShort:
+		return BuildShort(f.Control, f.Address), nil
+	}
+	c := Codec{Dialect: f.Dialect}
+	skel, err := c.BuildSkeleton(f.Control, f.Address, f.Data)
+	if err != nil {
+		return nil, err
+	}
+	return c.AppendChecksum(skel, string(f.ChecksumKind)), nil
+}
+
+// Codec описывает параметры разбора и сборки кадров.
+// Нулевое значение соответствует упрощённому диалекту с автоопределением контрольной суммы
+type Codec struct {
+	Dialect Dialect
+	// Checksum - алгоритм контрольной суммы из конфигурации
+	Checksum ChecksumKind
+	// Strict - принимать только Checksum; иначе подходит любой известный алгоритм
+	Strict bool
+}
+
+// Decode разбирает кадр упрощённого диалекта (см. Codec.Decode)
+func Decode(b []byte) (*Frame, error) { return Codec{}.Decode(b) }
+
+// ExtractFrame извлекает кадр упрощённого диалекта (см. Codec.ExtractFrame)
+func ExtractFrame(buf *bytes.Buffer) ([]byte, Kind, bool) { return Codec{}.ExtractFrame(buf) }
+
+// PayloadData возвращает DATA кадра упрощённого диалекта (см. Codec.PayloadData)
+func PayloadData(frame []byte) []byte { return Codec{}.PayloadData(frame) }
+
+// BuildSkeleton формирует кадр упрощённого диалекта (см. Codec.BuildSkeleton)
+func BuildSkeleton(control byte, addr byte, data []byte) ([]byte, error) {
+	return Codec{}.BuildSkeleton(control, addr, data)
+}
+
+// AppendChecksum дописывает контрольную сумму к кадру упрощённого диалекта (см. Codec.AppendChecksum)
+func AppendChecksum(frameSoFar []byte, crcMode string) []byte {
+	return Codec{}.AppendChecksum(frameSoFar, crcMode)
+}
+
+// Decode проверяет контрольную сумму и разбирает кадр.
+// Совпавший алгоритм контрольной суммы сохраняется в ChecksumKind
+func (c Codec) Decode(b []byte) (*Frame, error) {
+	kind, err := c.VerifyChecksum(b)
+	if err != nil {
+		return nil, err
+	}
+	switch b[0] {
+	case SingleCharAck:
+		return &Frame{Kind: KindAck, Dialect: c.Dialect}, nil
+	case 0x10:
+		return &Frame{Kind: KindShort, Control: b[1], Address: b[2], ChecksumKind: kind, Dialect: c.Dialect}, nil
+	}
+	// LEN должен покрывать CONTROL и ADDR, а хвост - ровно checksum + 0x16
+	hdr := c.Dialect.HeaderLen()
+	lenByte := int(b[1])
+	if lenByte < 2 || hdr+lenByte+kind.Size()+1 != len(b) {
+		return nil, ErrLengthMismatch
+	}
+	data := make([]byte, lenByte-2)
+	copy(data, b[hdr+2:hdr+lenByte])
+	return &Frame{
+		Control:      b[hdr],
+		Address:      b[hdr+1],
+		Data:         data,
+		ChecksumKind: kind,
+		Dialect:      c.Dialect,
+	}, nil
+}
+
+// ExtractFrame ищет и извлекает первый полный фрейм из буфера
+// Возвращает копию фрейма, его вид и true, если найденный фрейм удалён из буфера.
+// Мусор перед кадром и ложные стартовые байты отбрасываются в пределах одного вызова
+func (c Codec) ExtractFrame(buf *bytes.Buffer) ([]byte, Kind, bool) {
+	skip, n, kind, _ := c.scan(buf.Bytes())
+	buf.Next(skip)
+	if n == 0 {
+		return nil, 0, false
+	}
+	frame := make([]byte, n)
+	copy(frame, buf.Next(n))
+	return frame, kind, true
+}
+
+// frameStatus - результат проверки кадра с текущей позиции
+type frameStatus int
+
+const (
+	frameComplete   frameStatus = iota // кадр собран полностью
+	frameIncomplete                    // нужно больше данных
+	frameInvalid                       // ложный старт: структура не сходится
+)
+
+// scan ищет первый полный кадр в b, пропуская ложные стартовые байты.
+// skip - сколько байт мусора перед кадром (или перед незавершённым кадром) можно отбросить,
+// n - длина найденного кадра (0 - кадра пока нет), resyncs - число ложных стартов
+func (c Codec) scan(b []byte) (skip int, n int, kind Kind, resyncs uint64) {
+	pos := 0
+	for {
+		i := indexStart(b[pos:])
+		if i < 0 {
+			return len(b), 0, 0, resyncs
+		}
+		start := pos + i
+		n, kind, st := c.frameAt(b[start:])
+		switch st {
+		case frameComplete:
+			return start, n, kind, resyncs
+		case frameIncomplete:
+			return start, 0, 0, resyncs
+		}
+		// Сдвигаемся на байт и ищем следующий старт в том же вызове
+		resyncs++
+		pos = start + 1
+	}
+}
+
+// frameAt проверяет структуру кадра, начинающегося с b[0] (стартовый байт)
+func (c Codec) frameAt(b []byte) (int, Kind, frameStatus) {
+	switch b[0] {
+	case SingleCharAck:
+		return 1, KindAck, frameComplete
+	case 0x10:
+		if len(b) < ShortFrameLen {
+			return 0, 0, frameIncomplete
+		}
+		// Короткий кадр обязан заканчиваться 0x16 - иначе это не начало кадра
+		if b[ShortFrameLen-1] != 0x16 {
+			return 0, 0, frameInvalid
+		}
+		return ShortFrameLen, KindShort, frameComplete
+	}
+
+	// Нужен весь заголовок: 0x68, LEN, 0x68 (или 0x68, LEN, LEN, 0x68)
+	hdr := c.Dialect.HeaderLen()
+	if len(b) < hdr {
+		return 0, 0, frameIncomplete
+	}
+
+	// Проверяем второй 0x68 (и совпадение байт LEN для ft12)
+	if !c.Dialect.checkHeader(b) {
+		return 0, 0, frameInvalid
+	}
+
+	lenByte := int(b[1])
+	payloadEnd := hdr + lenByte
+
+	// Убедимся, что в буфере есть хотя бы payload + checksum + 0x16
+	// (в строгом режиме - контрольная сумма именно настроенной ширины)
+	need := payloadEnd + 2
+	if c.Strict {
+		need = payloadEnd + c.checksum().Size() + 1
+	}
+	if len(b) < need {
+		return 0, 0, frameIncomplete
+	}
+
+	// Ищем 0x16 сразу за контрольной суммой. В строгом режиме первой проверяется
+	// позиция для настроенного алгоритма, чтобы 0x16 внутри CRC не обрезал кадр
+	maxEnd := 0
+	for _, k := range c.candidates() {
+		endIdx := payloadEnd + k.Size()
+		if endIdx < len(b) && b[endIdx] == 0x16 {
+			return endIdx + 1, KindVariable, frameComplete
+		}
+		if endIdx > maxEnd {
+			maxEnd = endIdx
+		}
+	}
+
+	// Все возможные позиции терминатора уже в буфере, но 0x16 нет ни в одной
+	if maxEnd < len(b) {
+		return 0, 0, frameInvalid
+	}
+	return 0, 0, frameIncomplete
+}
+
+// PayloadData возвращает DATA (без CONTROL и ADDR) из фрейма
+// Использует LEN (frame[1]) для вычисления границ; при несогласованной длине возвращает nil
+func (c Codec) PayloadData(frame []byte) []byte {
+	hdr := c.Dialect.HeaderLen()
+	if len(frame) < hdr+4 {
+		return nil
+	}
+	lenByte := int(frame[1])
+	if lenByte < 2 {
+		return nil
+	}
+	payloadStart := hdr
+	payloadEnd := payloadStart + lenByte
+
+	// Должно оставаться минимум checksum + 0x16
+	if payloadEnd > len(frame)-2 {
+		return nil
+	}
+
+	// DATA начинается после CONTROL и ADDR
+	dataStart := payloadStart + 2
+	return frame[dataStart:payloadEnd]
+}
+
+// BuildSkeleton формирует базовую часть кадра без CRC и 0x16.
+// DATA длиннее MaxDataLen не помещается в LEN - возвращается ErrDataTooLong (см. EncodeSegmented)
+func (c Codec) BuildSkeleton(control byte, addr byte, data []byte) ([]byte, error) {
+	if len(data) > MaxDataLen {
+		return nil, ErrDataTooLong
+	}
+	lenByte := byte(2 + len(data))
+	var b bytes.Buffer
+	b.Write(c.Dialect.header(lenByte))
+	b.WriteByte(control)
+	b.WriteByte(addr)
+	b.Write(data)
+	return b.Bytes(), nil
+}
+
+// AppendChecksum добавляет контрольную сумму алгоритма crcMode из реестра и терминатор 0x16.
+// Незарегистрированное имя считается sum: имена проверяются при старте через LookupChecksum
+func (c Codec) AppendChecksum(frameSoFar []byte, crcMode string) []byte {
+	body := frameSoFar[c.Dialect.HeaderLen():]
+	alg, ok := lookup(ChecksumKind(crcMode))
+	if !ok {
+		alg = sumAlgorithm{}
+	}
+	frameSoFar = append(frameSoFar, alg.Sum(body)...)
+	return append(frameSoFar, 0x16)
+}
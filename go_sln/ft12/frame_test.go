@@ -0,0 +1,160 @@
+package ft12
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Кадр, собранный Encode, разбирается Decode обратно для обоих диалектов и всех
+// встроенных алгоритмов контрольной суммы
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	data := []byte{0x01, 0x32, 0x30, 0x16, 0x68, 0x10, 0xE5}
+	for _, d := range []Dialect{DialectSimplified, DialectFT12} {
+		for _, name := range ChecksumNames() {
+			kind := ChecksumKind(name)
+			in := Frame{Control: 0x53, Address: 0x01, Data: data, ChecksumKind: kind, Dialect: d}
+			b, err := in.Encode()
+			if err != nil {
+				t.Fatalf("%s/%s: Encode: %v", d, kind, err)
+			}
+			if want := d.HeaderLen() + 2 + len(data) + kind.Size() + 1; len(b) != want {
+				t.Fatalf("%s/%s: frame length %d, want %d", d, kind, len(b), want)
+			}
+			out, err := Codec{Dialect: d, Checksum: kind}.Decode(b)
+			if err != nil {
+				t.Fatalf("%s/%s: Decode(% X): %v", d, kind, b, err)
+			}
+			if out.Kind != KindVariable || out.Control != in.Control || out.Address != in.Address ||
+				!bytes.Equal(out.Data, data) || out.ChecksumKind != kind || out.Dialect != d {
+				t.Errorf("%s/%s: Decode = %+v, want %+v", d, kind, *out, in)
+			}
+		}
+	}
+}
+
+func TestEncodeKnownFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		f    Frame
+		want []byte
+	}{
+		{"simplified", Frame{Control: 0x73, Address: 0x01, Data: []byte{0x01}, ChecksumKind: ChecksumSum},
+			[]byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}},
+		{"ft12", Frame{Control: 0x73, Address: 0x01, Data: []byte{0x01}, ChecksumKind: ChecksumSum, Dialect: DialectFT12},
+			[]byte{0x68, 0x03, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}},
+		{"short", Frame{Kind: KindShort, Control: 0x49, Address: 0x01},
+			[]byte{0x10, 0x49, 0x01, 0x4A, 0x16}},
+		{"ack", Frame{Kind: KindAck}, []byte{0xE5}},
+	}
+	for _, tt := range tests {
+		got, err := tt.f.Encode()
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: Encode = % X, want % X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeShortAndAck(t *testing.T) {
+	f, err := Decode([]byte{0x10, 0x49, 0x01, 0x4A, 0x16})
+	if err != nil {
+		t.Fatalf("Decode short: %v", err)
+	}
+	if f.Kind != KindShort || f.Control != 0x49 || f.Address != 0x01 || f.ChecksumKind != ChecksumSum {
+		t.Errorf("Decode short = %+v", *f)
+	}
+	f, err = Decode([]byte{0xE5})
+	if err != nil {
+		t.Fatalf("Decode ack: %v", err)
+	}
+	if f.Kind != KindAck {
+		t.Errorf("Decode ack: kind %s", f.Kind)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		frame   []byte
+		want    error
+	}{
+		{"empty", DialectSimplified, nil, ErrFrameTooShort},
+		{"too short", DialectSimplified, []byte{0x68, 0x02, 0x68, 0x16}, ErrFrameTooShort},
+		{"no end byte", DialectSimplified, []byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x17}, ErrNoEndByte},
+		{"bad header", DialectSimplified, []byte{0x68, 0x03, 0x69, 0x73, 0x01, 0x01, 0x75, 0x16}, ErrBadHeader},
+		{"ft12 length bytes differ", DialectFT12, []byte{0x68, 0x03, 0x04, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}, ErrBadHeader},
+		{"simplified frame as ft12", DialectFT12, []byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}, ErrBadHeader},
+		{"bad checksum", DialectSimplified, []byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x76, 0x16}, ErrChecksumMismatch},
+		{"ack with tail", DialectSimplified, []byte{0xE5, 0x16}, ErrLengthMismatch},
+		{"short truncated", DialectSimplified, []byte{0x10, 0x49, 0x01, 0x4A}, ErrFrameTooShort},
+		{"short bad checksum", DialectSimplified, []byte{0x10, 0x49, 0x01, 0x4B, 0x16}, ErrChecksumMismatch},
+		{"short no end byte", DialectSimplified, []byte{0x10, 0x49, 0x01, 0x4A, 0x17}, ErrNoEndByte},
+	}
+	for _, tt := range tests {
+		_, err := Codec{Dialect: tt.dialect}.Decode(tt.frame)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Decode(% X) error = %v, want %v", tt.name, tt.frame, err, tt.want)
+		}
+	}
+}
+
+// ExtractFrame выделяет кадры всех видов из склеенного потока с мусором и оставляет
+// незавершённый кадр в буфере
+func TestExtractFrame(t *testing.T) {
+	for _, d := range []Dialect{DialectSimplified, DialectFT12} {
+		c := Codec{Dialect: d}
+		variable, err := (&Frame{Control: 0x08, Address: 0x01, Data: []byte{0x01, 0x68}, Dialect: d}).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		short := BuildShort(0x49, 0x01)
+		var buf bytes.Buffer
+		buf.Write([]byte{0x00, 0xFF})
+		buf.Write(variable)
+		buf.Write([]byte{SingleCharAck})
+		buf.Write(short)
+		buf.Write(variable[:4])
+
+		for i, want := range []struct {
+			kind  Kind
+			frame []byte
+		}{{KindVariable, variable}, {KindAck, []byte{SingleCharAck}}, {KindShort, short}} {
+			frame, kind, ok := c.ExtractFrame(&buf)
+			if !ok || kind != want.kind || !bytes.Equal(frame, want.frame) {
+				t.Fatalf("%s: frame %d = % X (%s, %v), want % X (%s)", d, i, frame, kind, ok, want.frame, want.kind)
+			}
+		}
+		if _, _, ok := c.ExtractFrame(&buf); ok {
+			t.Fatalf("%s: incomplete frame extracted", d)
+		}
+		if !bytes.Equal(buf.Bytes(), variable[:4]) {
+			t.Errorf("%s: buffer after extract = % X, want % X", d, buf.Bytes(), variable[:4])
+		}
+	}
+}
+
+func TestPayloadData(t *testing.T) {
+	frame := []byte{0x68, 0x05, 0x05, 0x68, 0x08, 0x01, 0x01, 0x02, 0x03, 0x0F, 0x16}
+	if got := (Codec{Dialect: DialectFT12}).PayloadData(frame); !bytes.Equal(got, []byte{0x01, 0x02, 0x03}) {
+		t.Errorf("PayloadData = % X", got)
+	}
+	// LEN больше, чем байт в кадре
+	if got := PayloadData([]byte{0x68, 0x20, 0x68, 0x08, 0x01, 0x09, 0x16}); got != nil {
+		t.Errorf("PayloadData with bad LEN = % X, want nil", got)
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	for _, s := range []string{"simplified", "ft12"} {
+		if d, err := ParseDialect(s); err != nil || string(d) != s {
+			t.Errorf("ParseDialect(%q) = %q, %v", s, d, err)
+		}
+	}
+	if _, err := ParseDialect("iec101"); err == nil {
+		t.Errorf("ParseDialect(iec101): no error")
+	}
+}
@@ -0,0 +1,3 @@
+module sln/ft12
+
+go 1.25
@@ -0,0 +1,264 @@
+package ft12
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Algorithm - алгоритм контрольной суммы, доступный по имени через реестр
+type Algorithm interface {
+	// Size возвращает ширину контрольной суммы в байтах
+	Size() int
+	// Sum возвращает контрольную сумму data в порядке передачи по линии
+	Sum(data []byte) []byte
+}
+
+// Встроенные алгоритмы. ChecksumSum и ChecksumCRC16 объявлены в checksum.go
+const (
+	ChecksumXOR8        ChecksumKind = "xor8"         // XOR всех байт, 1 байт
+	ChecksumCRC8        ChecksumKind = "crc8"         // CRC-8 (poly 0x07)
+	ChecksumCRC16BE     ChecksumKind = "crc16-be"     // CRC-16/Modbus, big-endian
+	ChecksumCRC16CCITT  ChecksumKind = "crc16-ccitt"  // CRC-16/CCITT-FALSE, big-endian
+	ChecksumCRC16X25    ChecksumKind = "crc16-x25"    // CRC-16/X.25, little-endian
+	ChecksumCRC16Kermit ChecksumKind = "crc16-kermit" // CRC-16/KERMIT, little-endian
+)
+
+var registry = struct {
+	sync.RWMutex
+	byName map[ChecksumKind]Algorithm
+	order  []ChecksumKind
+}{byName: map[ChecksumKind]Algorithm{}}
+
+func init() {
+	mustRegister(ChecksumSum, sumAlgorithm{})
+	mustRegister(ChecksumCRC16, CRCParams{Width: 16, Poly: 0x8005, Init: 0xFFFF, Reflect: true})
+	mustRegister(ChecksumXOR8, xorAlgorithm{})
+	mustRegister(ChecksumCRC8, CRCParams{Width: 8, Poly: 0x07})
+	mustRegister(ChecksumCRC16BE, CRCParams{Width: 16, Poly: 0x8005, Init: 0xFFFF, Reflect: true, BigEndian: true})
+	mustRegister(ChecksumCRC16CCITT, CRCParams{Width: 16, Poly: 0x1021, Init: 0xFFFF, BigEndian: true})
+	mustRegister(ChecksumCRC16X25, CRCParams{Width: 16, Poly: 0x1021, Init: 0xFFFF, Reflect: true, XorOut: 0xFFFF})
+	mustRegister(ChecksumCRC16Kermit, CRCParams{Width: 16, Poly: 0x1021, Reflect: true})
+}
+
+func mustRegister(name ChecksumKind, alg Algorithm) {
+	if err := Register(name, alg); err != nil {
+		panic(err)
+	}
+}
+
+// Register добавляет алгоритм в реестр. Повторная регистрация имени - ошибка
+func Register(name ChecksumKind, alg Algorithm) error {
+	if name == "" {
+		return fmt.Errorf("checksum name is empty")
+	}
+	if p, ok := alg.(CRCParams); ok {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("checksum %q: %w", name, err)
+		}
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, dup := registry.byName[name]; dup {
+		return fmt.Errorf("checksum %q already registered", name)
+	}
+	registry.byName[name] = alg
+	registry.order = append(registry.order, name)
+	return nil
+}
+
+// LookupChecksum возвращает алгоритм по имени; неизвестное имя - ошибка
+func LookupChecksum(name string) (Algorithm, error) {
+	if alg, ok := lookup(ChecksumKind(name)); ok {
+		return alg, nil
+	}
+	return nil, fmt.Errorf("unknown checksum %q (registered: %s)", name, strings.Join(ChecksumNames(), ", "))
+}
+
+// ChecksumNames возвращает отсортированный список зарегистрированных имён
+func ChecksumNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	out := make([]string, 0, len(registry.order))
+	for _, k := range registry.order {
+		out = append(out, string(k))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func lookup(name ChecksumKind) (Algorithm, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	alg, ok := registry.byName[name]
+	return alg, ok
+}
+
+// registered возвращает имена в порядке регистрации
+func registered() []ChecksumKind {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]ChecksumKind(nil), registry.order...)
+}
+
+// sumAlgorithm - сумма байт mod 256
+type sumAlgorithm struct{}
+
+func (sumAlgorithm) Size() int              { return 1 }
+func (sumAlgorithm) Sum(data []byte) []byte { return []byte{ComputeSum(data)} }
+
+// xorAlgorithm - XOR всех байт
+type xorAlgorithm struct{}
+
+func (xorAlgorithm) Size() int { return 1 }
+func (xorAlgorithm) Sum(data []byte) []byte {
+	var x byte
+	for _, b := range data {
+		x ^= b
+	}
+	return []byte{x}
+}
+
+// CRCParams - параметрическое описание CRC шириной 8 или 16 бит (модель Rocksoft).
+// Poly и Init задаются в нормальной (неотражённой) форме
+type CRCParams struct {
+	Width     int    // 8 или 16
+	Poly      uint16 // полином без старшего бита
+	Init      uint16 // начальное значение регистра
+	Reflect   bool   // отражение входных байт и результата (RefIn = RefOut)
+	XorOut    uint16 // маска, накладываемая на результат
+	BigEndian bool   // порядок байт 16-битной CRC в кадре
+}
+
+func (p CRCParams) validate() error {
+	if p.Width != 8 && p.Width != 16 {
+		return fmt.Errorf("unsupported crc width %d (want 8 | 16)", p.Width)
+	}
+	if p.Width == 8 && (p.Poly > 0xFF || p.Init > 0xFF || p.XorOut > 0xFF) {
+		return fmt.Errorf("crc8 parameters exceed 8 bits")
+	}
+	return nil
+}
+
+// Size возвращает ширину CRC в байтах
+func (p CRCParams) Size() int { return p.Width / 8 }
+
+// Compute считает CRC по параметрам
+func (p CRCParams) Compute(data []byte) uint16 {
+	w := uint(p.Width)
+	mask := uint32(1)<<w - 1
+	if p.Reflect {
+		poly := reflectBits(uint32(p.Poly), w)
+		crc := reflectBits(uint32(p.Init), w)
+		for _, b := range data {
+			crc ^= uint32(b)
+			for i := 0; i < 8; i++ {
+				if crc&1 != 0 {
+					crc = (crc >> 1) ^ poly
+				} else {
+					crc >>= 1
+				}
+			}
+		}
+		return uint16((crc ^ uint32(p.XorOut)) & mask)
+	}
+	top := uint32(1) << (w - 1)
+	crc := uint32(p.Init)
+	for _, b := range data {
+		crc ^= uint32(b) << (w - 8)
+		for i := 0; i < 8; i++ {
+			if crc&top != 0 {
+				crc = (crc << 1) ^ uint32(p.Poly)
+			} else {
+				crc <<= 1
+			}
+			crc &= mask
+		}
+	}
+	return uint16((crc ^ uint32(p.XorOut)) & mask)
+}
+
+// Sum возвращает CRC в порядке байт из BigEndian
+func (p CRCParams) Sum(data []byte) []byte {
+	crc := p.Compute(data)
+	if p.Width == 8 {
+		return []byte{byte(crc)}
+	}
+	if p.BigEndian {
+		return []byte{byte(crc >> 8), byte(crc)}
+	}
+	return []byte{byte(crc), byte(crc >> 8)}
+}
+
+func reflectBits(v uint32, width uint) uint32 {
+	var r uint32
+	for i := uint(0); i < width; i++ {
+		if v&(1<<i) != 0 {
+			r |= 1 << (width - 1 - i)
+		}
+	}
+	return r
+}
+
+// ParseCRCDef разбирает описание CRC из командной строки вида
+// "name:width=16,poly=0x1021,init=0xFFFF,reflect=true,xorout=0,order=be"
+func ParseCRCDef(def string) (ChecksumKind, CRCParams, error) {
+	name, spec, ok := strings.Cut(def, ":")
+	if !ok || name == "" {
+		return "", CRCParams{}, fmt.Errorf("crc definition %q: want name:key=value,...", def)
+	}
+	p := CRCParams{Width: 16}
+	for _, kv := range strings.Split(spec, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return "", CRCParams{}, fmt.Errorf("crc definition %q: bad parameter %q", def, kv)
+		}
+		var err error
+		switch strings.ToLower(key) {
+		case "width":
+			p.Width, err = strconv.Atoi(val)
+		case "poly":
+			p.Poly, err = parseUint16(val)
+		case "init":
+			p.Init, err = parseUint16(val)
+		case "xorout":
+			p.XorOut, err = parseUint16(val)
+		case "reflect":
+			p.Reflect, err = strconv.ParseBool(val)
+		case "order":
+			switch strings.ToLower(val) {
+			case "le":
+				p.BigEndian = false
+			case "be":
+				p.BigEndian = true
+			default:
+				err = fmt.Errorf("want le | be")
+			}
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return "", CRCParams{}, fmt.Errorf("crc definition %q: %s: %v", def, key, err)
+		}
+	}
+	if err := p.validate(); err != nil {
+		return "", CRCParams{}, fmt.Errorf("crc definition %q: %w", def, err)
+	}
+	return ChecksumKind(name), p, nil
+}
+
+// RegisterCRCDef разбирает описание ParseCRCDef и регистрирует алгоритм
+func RegisterCRCDef(def string) error {
+	name, p, err := ParseCRCDef(def)
+	if err != nil {
+		return err
+	}
+	return Register(name, p)
+}
+
+func parseUint16(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 0, 16)
+	return uint16(v), err
+}
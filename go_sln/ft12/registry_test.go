@@ -0,0 +1,148 @@
+package ft12
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var checkInput = []byte("123456789")
+
+// Контрольные значения по каталогу CRC (check для "123456789")
+func TestCRCParamsCheckValues(t *testing.T) {
+	tests := []struct {
+		name string
+		p    CRCParams
+		want uint16
+	}{
+		{"CRC-16/MODBUS", CRCParams{Width: 16, Poly: 0x8005, Init: 0xFFFF, Reflect: true}, 0x4B37},
+		{"CRC-16/ARC", CRCParams{Width: 16, Poly: 0x8005, Reflect: true}, 0xBB3D},
+		{"CRC-16/CCITT-FALSE", CRCParams{Width: 16, Poly: 0x1021, Init: 0xFFFF}, 0x29B1},
+		{"CRC-16/XMODEM", CRCParams{Width: 16, Poly: 0x1021}, 0x31C3},
+		{"CRC-16/X-25", CRCParams{Width: 16, Poly: 0x1021, Init: 0xFFFF, Reflect: true, XorOut: 0xFFFF}, 0x906E},
+		{"CRC-16/KERMIT", CRCParams{Width: 16, Poly: 0x1021, Reflect: true}, 0x2189},
+		{"CRC-8", CRCParams{Width: 8, Poly: 0x07}, 0xF4},
+		{"CRC-8/MAXIM", CRCParams{Width: 8, Poly: 0x31, Reflect: true}, 0xA1},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Compute(checkInput); got != tt.want {
+			t.Errorf("%s: Compute = 0x%04X, want 0x%04X", tt.name, got, tt.want)
+		}
+	}
+	if got := ComputeCRC16(checkInput); got != 0x4B37 {
+		t.Errorf("ComputeCRC16 = 0x%04X, want 0x4B37", got)
+	}
+}
+
+// Встроенные алгоритмы реестра: значение и порядок байт в кадре
+func TestBuiltinChecksums(t *testing.T) {
+	tests := []struct {
+		name ChecksumKind
+		want []byte
+	}{
+		{ChecksumSum, []byte{0xDD}},
+		{ChecksumXOR8, []byte{0x31}},
+		{ChecksumCRC8, []byte{0xF4}},
+		{ChecksumCRC16, []byte{0x37, 0x4B}},
+		{ChecksumCRC16BE, []byte{0x4B, 0x37}},
+		{ChecksumCRC16CCITT, []byte{0x29, 0xB1}},
+		{ChecksumCRC16X25, []byte{0x6E, 0x90}},
+		{ChecksumCRC16Kermit, []byte{0x89, 0x21}},
+	}
+	for _, tt := range tests {
+		alg, err := LookupChecksum(string(tt.name))
+		if err != nil {
+			t.Fatalf("LookupChecksum(%s): %v", tt.name, err)
+		}
+		if got := alg.Sum(checkInput); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: Sum = % X, want % X", tt.name, got, tt.want)
+		}
+		if alg.Size() != len(tt.want) || tt.name.Size() != len(tt.want) {
+			t.Errorf("%s: Size = %d/%d, want %d", tt.name, alg.Size(), tt.name.Size(), len(tt.want))
+		}
+	}
+}
+
+func TestParseCRCDef(t *testing.T) {
+	name, p, err := ParseCRCDef("xmodem:width=16,poly=0x1021,init=0,reflect=false,xorout=0,order=be")
+	if err != nil {
+		t.Fatalf("ParseCRCDef: %v", err)
+	}
+	want := CRCParams{Width: 16, Poly: 0x1021, BigEndian: true}
+	if name != "xmodem" || p != want {
+		t.Fatalf("ParseCRCDef = %q, %+v; want xmodem, %+v", name, p, want)
+	}
+	// Ширина по умолчанию 16, ключи без учёта регистра, пробелы вокруг параметров
+	_, p, err = ParseCRCDef("maxim: Width=8, POLY=0x31, reflect=true")
+	if err != nil {
+		t.Fatalf("ParseCRCDef: %v", err)
+	}
+	if p.Compute(checkInput) != 0xA1 {
+		t.Errorf("maxim: Compute = 0x%02X, want 0xA1", p.Compute(checkInput))
+	}
+
+	bad := []string{
+		"nocolon",
+		":poly=0x1021",
+		"x:poly",
+		"x:poly=0x10000",
+		"x:init=zz",
+		"x:reflect=maybe",
+		"x:order=middle",
+		"x:color=red",
+		"x:width=12",
+		"x:width=8,poly=0x107",
+	}
+	for _, def := range bad {
+		if _, _, err := ParseCRCDef(def); err == nil {
+			t.Errorf("ParseCRCDef(%q): no error", def)
+		} else if !strings.Contains(err.Error(), def) {
+			t.Errorf("ParseCRCDef(%q): error %q does not name the definition", def, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	const name = "test-xmodem"
+	// Реестр общий для процесса: при go test -count=N алгоритм уже зарегистрирован
+	if _, ok := lookup(name); !ok {
+		if err := RegisterCRCDef(name + ":poly=0x1021,order=be"); err != nil {
+			t.Fatalf("RegisterCRCDef: %v", err)
+		}
+	}
+	if err := RegisterCRCDef(name + ":poly=0x1021"); err == nil {
+		t.Errorf("duplicate RegisterCRCDef: no error")
+	}
+	if err := Register(ChecksumSum, xorAlgorithm{}); err == nil {
+		t.Errorf("Register(sum): no error for a built-in name")
+	}
+	if err := Register("", sumAlgorithm{}); err == nil {
+		t.Errorf("Register(\"\"): no error")
+	}
+	if err := Register("test-bad", CRCParams{Width: 32}); err == nil {
+		t.Errorf("Register(width 32): no error")
+	}
+	if _, err := LookupChecksum("test-missing"); err == nil {
+		t.Errorf("LookupChecksum(test-missing): no error")
+	}
+
+	found := false
+	for _, n := range ChecksumNames() {
+		found = found || n == name
+	}
+	if !found {
+		t.Errorf("ChecksumNames() = %v, missing %s", ChecksumNames(), name)
+	}
+
+	// Кадр, подписанный новым алгоритмом, принимается, когда алгоритм настроен,
+	// и определяется в диагностике строгого режима
+	frame := signed(t, DialectSimplified, name)
+	if kind, err := (Codec{Checksum: name}).VerifyChecksum(frame); err != nil || kind != name {
+		t.Errorf("VerifyChecksum = %q, %v; want %s", kind, err, name)
+	}
+	var ce *ChecksumError
+	if _, err := (Codec{Strict: true}).VerifyChecksum(frame); !errors.As(err, &ce) || ce.Detected != name {
+		t.Errorf("strict VerifyChecksum = %v, want detected %s", err, name)
+	}
+}
@@ -0,0 +1,289 @@
+// Package regmap описывает карту регистров (параметров) прибора TTR20,
+// загружаемую из JSON. Одно и то же описание использует эмулятор (хранение и выдача значений)
+// и клиент (разбор прочитанных байт в инженерные единицы).
+//
+// Пример файла:
+//
+//	{
+//	  "registers": [
+//	    {"name": "serial", "address": 1, "type": "ascii", "length": 12, "access": "r", "value": "TTR20-000123"},
+//	    {"name": "temperature", "address": 16, "type": "i32", "scale": 0.01, "units": "C", "access": "r", "value": 21.5},
+//	    {"name": "setpoint", "address": 32, "type": "u16", "scale": 0.1, "units": "bar", "access": "rw", "value": 4.2}
+//	  ]
+//	}
+//
+// Значения передаются по линии в сыром виде little-endian: raw = value / scale (для целых типов).
+package regmap
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Type - тип значения регистра
+type Type string
+
+const (
+	TypeU8    Type = "u8"    // 1 байт без знака
+	TypeU16   Type = "u16"   // 2 байта без знака
+	TypeI32   Type = "i32"   // 4 байта со знаком
+	TypeFloat Type = "float" // IEEE 754 float32, 4 байта
+	TypeASCII Type = "ascii" // строка фиксированной длины Length, дополняется нулями
+)
+
+// Access - права доступа к регистру
+type Access string
+
+const (
+	AccessRead      Access = "r"
+	AccessWrite     Access = "w"
+	AccessReadWrite Access = "rw"
+)
+
+// Register - описание одного параметра прибора
+type Register struct {
+	Name    string  `json:"name"`
+	Address uint16  `json:"address"`
+	Type    Type    `json:"type"`
+	Length  int     `json:"length,omitempty"` // только для ascii
+	Scale   float64 `json:"scale,omitempty"`  // множитель raw -> value для целых типов (0 - 1)
+	Units   string  `json:"units,omitempty"`
+	Access  Access  `json:"access"`
+	// Initial - начальное значение в эмуляторе: число или строка
+	Initial json.RawMessage `json:"value,omitempty"`
+}
+
+// Value - значение параметра в инженерных единицах
+type Value struct {
+	Num  float64 // числовые типы (с учётом Scale)
+	Text string  // ascii
+}
+
+// Map - карта регистров прибора
+type Map struct {
+	Registers []*Register `json:"registers"`
+
+	byAddr map[uint16]*Register
+	byName map[string]*Register
+}
+
+// Load читает и проверяет карту регистров из JSON-файла
+func Load(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Parse разбирает и проверяет карту регистров
+func Parse(data []byte) (*Map, error) {
+	var m Map
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	m.byAddr = map[uint16]*Register{}
+	m.byName = map[string]*Register{}
+	for i, r := range m.Registers {
+		if r == nil {
+			return nil, fmt.Errorf("register %d: null entry", i)
+		}
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		if _, dup := m.byAddr[r.Address]; dup {
+			return nil, fmt.Errorf("register %q: duplicate address %d", r.Name, r.Address)
+		}
+		if _, dup := m.byName[r.Name]; dup {
+			return nil, fmt.Errorf("duplicate register name %q", r.Name)
+		}
+		m.byAddr[r.Address] = r
+		m.byName[r.Name] = r
+	}
+	return &m, nil
+}
+
+// ByAddress ищет регистр по адресу
+func (m *Map) ByAddress(addr uint16) (*Register, bool) {
+	r, ok := m.byAddr[addr]
+	return r, ok
+}
+
+// ByName ищет регистр по имени
+func (m *Map) ByName(name string) (*Register, bool) {
+	r, ok := m.byName[name]
+	return r, ok
+}
+
+func (r *Register) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("register at address %d: empty name", r.Address)
+	}
+	switch r.Type {
+	case TypeU8, TypeU16, TypeI32, TypeFloat:
+	case TypeASCII:
+		if r.Length < 1 || r.Length > 200 {
+			return fmt.Errorf("register %q: ascii length %d out of range 1..200", r.Name, r.Length)
+		}
+	default:
+		return fmt.Errorf("register %q: unknown type %q (want u8 | u16 | i32 | float | ascii)", r.Name, r.Type)
+	}
+	switch r.Access {
+	case AccessRead, AccessWrite, AccessReadWrite:
+	default:
+		return fmt.Errorf("register %q: unknown access %q (want r | w | rw)", r.Name, r.Access)
+	}
+	if r.Scale < 0 {
+		return fmt.Errorf("register %q: negative scale", r.Name)
+	}
+	if _, err := r.InitialRaw(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Readable - регистр можно читать
+func (r *Register) Readable() bool { return r.Access == AccessRead || r.Access == AccessReadWrite }
+
+// Writable - регистр можно записывать
+func (r *Register) Writable() bool { return r.Access == AccessWrite || r.Access == AccessReadWrite }
+
+// Size возвращает длину сырого значения в байтах
+func (r *Register) Size() int {
+	switch r.Type {
+	case TypeU8:
+		return 1
+	case TypeU16:
+		return 2
+	case TypeASCII:
+		return r.Length
+	}
+	return 4
+}
+
+func (r *Register) scale() float64 {
+	if r.Scale == 0 {
+		return 1
+	}
+	return r.Scale
+}
+
+// InitialRaw кодирует начальное значение из файла (нули, если оно не задано)
+func (r *Register) InitialRaw() ([]byte, error) {
+	if len(r.Initial) == 0 {
+		return make([]byte, r.Size()), nil
+	}
+	var v Value
+	if r.Type == TypeASCII {
+		if err := json.Unmarshal(r.Initial, &v.Text); err != nil {
+			return nil, fmt.Errorf("register %q: value: %v", r.Name, err)
+		}
+	} else if err := json.Unmarshal(r.Initial, &v.Num); err != nil {
+		return nil, fmt.Errorf("register %q: value: %v", r.Name, err)
+	}
+	return r.Encode(v)
+}
+
+// Encode переводит значение в инженерных единицах в сырые байты
+func (r *Register) Encode(v Value) ([]byte, error) {
+	if r.Type == TypeASCII {
+		if len(v.Text) > r.Length {
+			return nil, fmt.Errorf("register %q: text longer than %d bytes", r.Name, r.Length)
+		}
+		b := make([]byte, r.Length)
+		copy(b, v.Text)
+		return b, nil
+	}
+	if r.Type == TypeFloat {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v.Num)))
+		return b, nil
+	}
+	raw := math.Round(v.Num / r.scale())
+	switch r.Type {
+	case TypeU8:
+		if raw < 0 || raw > math.MaxUint8 {
+			return nil, fmt.Errorf("register %q: value %g out of range", r.Name, v.Num)
+		}
+		return []byte{byte(raw)}, nil
+	case TypeU16:
+		if raw < 0 || raw > math.MaxUint16 {
+			return nil, fmt.Errorf("register %q: value %g out of range", r.Name, v.Num)
+		}
+		b := make([]byte, 2)
+		binary.LittleEndian.PutUint16(b, uint16(raw))
+		return b, nil
+	}
+	// i32
+	if raw < math.MinInt32 || raw > math.MaxInt32 {
+		return nil, fmt.Errorf("register %q: value %g out of range", r.Name, v.Num)
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(int32(raw)))
+	return b, nil
+}
+
+// Decode переводит сырые байты в значение в инженерных единицах
+func (r *Register) Decode(raw []byte) (Value, error) {
+	if len(raw) != r.Size() {
+		return Value{}, fmt.Errorf("register %q: got %d bytes, want %d", r.Name, len(raw), r.Size())
+	}
+	switch r.Type {
+	case TypeASCII:
+		return Value{Text: strings.TrimRight(string(raw), "\x00")}, nil
+	case TypeFloat:
+		return Value{Num: float64(math.Float32frombits(binary.LittleEndian.Uint32(raw)))}, nil
+	case TypeU8:
+		return Value{Num: float64(raw[0]) * r.scale()}, nil
+	case TypeU16:
+		return Value{Num: float64(binary.LittleEndian.Uint16(raw)) * r.scale()}, nil
+	}
+	return Value{Num: float64(int32(binary.LittleEndian.Uint32(raw))) * r.scale()}, nil
+}
+
+// ParseValue разбирает значение из командной строки по типу регистра
+func (r *Register) ParseValue(s string) (Value, error) {
+	if r.Type == TypeASCII {
+		return Value{Text: s}, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("register %q: %v", r.Name, err)
+	}
+	return Value{Num: f}, nil
+}
+
+// Format печатает значение с единицами измерения, например "21.5 C" или "\"TTR20-000123\""
+func (r *Register) Format(v Value) string {
+	if r.Type == TypeASCII {
+		return strconv.Quote(v.Text)
+	}
+	s := strconv.FormatFloat(v.Num, 'g', -1, 64)
+	if r.Type != TypeFloat && r.scale() != 1 {
+		// Убираем хвосты двоичной арифметики (0.1 * 42 = 4.2000000000000002)
+		s = strconv.FormatFloat(v.Num, 'f', decimals(r.scale()), 64)
+	}
+	if r.Units != "" {
+		s += " " + r.Units
+	}
+	return s
+}
+
+// decimals - сколько знаков после запятой нужно для шага scale
+func decimals(scale float64) int {
+	n := 0
+	for n < 9 && math.Abs(scale-math.Round(scale)) > 1e-9 {
+		scale *= 10
+		n++
+	}
+	return n
+}
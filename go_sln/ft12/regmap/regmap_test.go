@@ -0,0 +1,179 @@
+package regmap
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const sample = `{
+  "registers": [
+    {"name": "serial",      "address": 1,  "type": "ascii", "length": 12, "access": "r", "value": "TTR20-000123"},
+    {"name": "channels",    "address": 3,  "type": "u8",    "access": "r", "value": 4},
+    {"name": "temperature", "address": 16, "type": "i32",   "scale": 0.01, "units": "C", "access": "r", "value": -12.35},
+    {"name": "pressure",    "address": 17, "type": "float", "units": "bar", "access": "r", "value": 3.75},
+    {"name": "setpoint",    "address": 32, "type": "u16",   "scale": 0.1, "units": "bar", "access": "rw", "value": 4.2},
+    {"name": "password",    "address": 48, "type": "u16",   "access": "w"}
+  ]
+}`
+
+func mustParse(t *testing.T) *Map {
+	t.Helper()
+	m, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return m
+}
+
+func TestParseAndLookup(t *testing.T) {
+	m := mustParse(t)
+	tests := []struct {
+		name    string
+		addr    uint16
+		size    int
+		initial []byte
+		r, w    bool
+		format  string
+	}{
+		{"serial", 1, 12, []byte("TTR20-000123"), true, false, `"TTR20-000123"`},
+		{"channels", 3, 1, []byte{4}, true, false, "4"},
+		{"temperature", 16, 4, []byte{0x2D, 0xFB, 0xFF, 0xFF}, true, false, "-12.35 C"},
+		{"pressure", 17, 4, []byte{0x00, 0x00, 0x70, 0x40}, true, false, "3.75 bar"},
+		{"setpoint", 32, 2, []byte{0x2A, 0x00}, true, true, "4.2 bar"},
+		{"password", 48, 2, []byte{0x00, 0x00}, false, true, "0"},
+	}
+	for _, tt := range tests {
+		reg, ok := m.ByName(tt.name)
+		if !ok {
+			t.Fatalf("ByName(%q): not found", tt.name)
+		}
+		if byAddr, ok := m.ByAddress(tt.addr); !ok || byAddr != reg {
+			t.Errorf("ByAddress(%d) = %v, %v", tt.addr, byAddr, ok)
+		}
+		if reg.Size() != tt.size || reg.Readable() != tt.r || reg.Writable() != tt.w {
+			t.Errorf("%s: size %d r %v w %v, want %d %v %v", tt.name, reg.Size(), reg.Readable(), reg.Writable(), tt.size, tt.r, tt.w)
+		}
+		raw, err := reg.InitialRaw()
+		if err != nil || !bytes.Equal(raw, tt.initial) {
+			t.Errorf("%s: InitialRaw = % X, %v; want % X", tt.name, raw, err, tt.initial)
+		}
+		v, err := reg.Decode(raw)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tt.name, err)
+		}
+		if got := reg.Format(v); got != tt.format {
+			t.Errorf("%s: Format = %q, want %q", tt.name, got, tt.format)
+		}
+	}
+	if _, ok := m.ByAddress(2); ok {
+		t.Errorf("ByAddress(2): found a register that is not in the map")
+	}
+}
+
+// Значение, разобранное из командной строки, проходит Encode/Decode без потерь в пределах шага scale
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	m := mustParse(t)
+	tests := []struct {
+		name, in, want string
+	}{
+		{"serial", "SN-1", `"SN-1"`},
+		{"channels", "255", "255"},
+		{"temperature", "-2147483.648", "-2147483.65 C"}, // рядом с MinInt32 * 0.01
+		{"temperature", "0.006", "0.01 C"},               // округление до шага
+		{"pressure", "-0.5", "-0.5 bar"},
+		{"setpoint", "6553.5", "6553.5 bar"},
+	}
+	for _, tt := range tests {
+		reg, _ := m.ByName(tt.name)
+		v, err := reg.ParseValue(tt.in)
+		if err != nil {
+			t.Fatalf("%s: ParseValue(%q): %v", tt.name, tt.in, err)
+		}
+		raw, err := reg.Encode(v)
+		if err != nil {
+			t.Fatalf("%s: Encode(%q): %v", tt.name, tt.in, err)
+		}
+		back, err := reg.Decode(raw)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tt.name, err)
+		}
+		if got := reg.Format(back); got != tt.want {
+			t.Errorf("%s: %q -> % X -> %q, want %q", tt.name, tt.in, raw, got, tt.want)
+		}
+	}
+}
+
+func TestValueErrors(t *testing.T) {
+	m := mustParse(t)
+	encode := []struct {
+		name string
+		v    Value
+	}{
+		{"serial", Value{Text: "0123456789ABC"}},
+		{"channels", Value{Num: 256}},
+		{"channels", Value{Num: -1}},
+		{"setpoint", Value{Num: 6553.6}},
+		{"temperature", Value{Num: 21474836.48}},
+	}
+	for _, tt := range encode {
+		reg, _ := m.ByName(tt.name)
+		if _, err := reg.Encode(tt.v); err == nil {
+			t.Errorf("%s: Encode(%+v): no error", tt.name, tt.v)
+		}
+	}
+	reg, _ := m.ByName("setpoint")
+	if _, err := reg.Decode([]byte{1, 2, 3}); err == nil {
+		t.Errorf("Decode of 3 bytes into u16: no error")
+	}
+	if _, err := reg.ParseValue("four"); err == nil {
+		t.Errorf("ParseValue(four): no error")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, reg, want string
+	}{
+		{"empty name", `{"address": 1, "type": "u8", "access": "r"}`, "empty name"},
+		{"unknown type", `{"name": "a", "address": 1, "type": "u64", "access": "r"}`, "unknown type"},
+		{"unknown access", `{"name": "a", "address": 1, "type": "u8", "access": "x"}`, "unknown access"},
+		{"ascii without length", `{"name": "a", "address": 1, "type": "ascii", "access": "r"}`, "ascii length"},
+		{"negative scale", `{"name": "a", "address": 1, "type": "u8", "scale": -1, "access": "r"}`, "negative scale"},
+		{"value out of range", `{"name": "a", "address": 1, "type": "u8", "access": "r", "value": 300}`, "out of range"},
+		{"value of wrong type", `{"name": "a", "address": 1, "type": "u8", "access": "r", "value": "x"}`, "value"},
+		{"null entry", `{"name": "a", "address": 1, "type": "u8", "access": "r"}, null`, "register 1: null entry"},
+		{"duplicate address", `{"name": "a", "address": 1, "type": "u8", "access": "r"},
+			{"name": "b", "address": 1, "type": "u8", "access": "r"}`, "duplicate address"},
+		{"duplicate name", `{"name": "a", "address": 1, "type": "u8", "access": "r"},
+			{"name": "a", "address": 2, "type": "u8", "access": "r"}`, "duplicate register name"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(`{"registers": [` + tt.reg + `]}`))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Parse error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	if _, err := Parse([]byte(`{"registers": `)); err == nil {
+		t.Errorf("Parse of truncated JSON: no error")
+	}
+}
+
+// Карта, которая поставляется с эмулятором (рядом с модулем ft12), загружается без ошибок
+func TestLoadShippedMap(t *testing.T) {
+	const path = "../../ttr20-regmap.json"
+	if _, err := os.Stat(path); err != nil {
+		t.Skipf("shipped map: %v", err)
+	}
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(m.Registers) == 0 {
+		t.Fatal("no registers")
+	}
+	if _, err := Load("missing.json"); err == nil {
+		t.Errorf("Load(missing.json): no error")
+	}
+}
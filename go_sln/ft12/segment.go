@@ -0,0 +1,119 @@
+package ft12
+
+import "fmt"
+
+// Заголовок сегмента (1 байт) в начале DATA каждого кадра многокадровой передачи:
+//
+//	bit7 FIN - последний сегмент
+//	bit6 FIR - первый сегмент
+//	bit0-5   - порядковый номер сегмента (mod 64)
+const (
+	SegmentFIN     = 0x80
+	SegmentFIR     = 0x40
+	SegmentSeqMask = 0x3F
+)
+
+// MaxSegmentedLen - ограничение на размер собранного сообщения
+const MaxSegmentedLen = 64 * 1024
+
+var (
+	ErrSegmentOrder   = &FrameError{"segment out of order"}
+	ErrSegmentTooLong = &FrameError{"segmented message too long"}
+)
+
+// Segment разбивает payload на сегменты не длиннее chunk байт полезных данных.
+// Каждый сегмент начинается с заголовка (FIN/FIR/seq); пустой payload даёт один сегмент
+func Segment(payload []byte, chunk int) [][]byte {
+	if chunk < 1 {
+		chunk = 1
+	}
+	var out [][]byte
+	for seq := 0; ; seq++ {
+		n := len(payload)
+		if n > chunk {
+			n = chunk
+		}
+		hdr := byte(seq & SegmentSeqMask)
+		if seq == 0 {
+			hdr |= SegmentFIR
+		}
+		if n == len(payload) {
+			hdr |= SegmentFIN
+		}
+		seg := make([]byte, 0, n+1)
+		seg = append(seg, hdr)
+		seg = append(seg, payload[:n]...)
+		out = append(out, seg)
+		payload = payload[n:]
+		if hdr&SegmentFIN != 0 {
+			return out
+		}
+	}
+}
+
+// EncodeSegmented собирает многокадровую передачу: DATA каждого кадра = prefix + заголовок сегмента + часть payload.
+// prefix (обычно код команды) повторяется в каждом кадре, чтобы кадры можно было диспетчеризовать по отдельности.
+// chunk - полезных байт на кадр; 0 или слишком большое значение - максимум, помещающийся в LEN
+func EncodeSegmented(tmpl Frame, prefix []byte, payload []byte, chunk int) ([][]byte, error) {
+	maxChunk := MaxDataLen - len(prefix) - 1
+	if maxChunk < 1 {
+		return nil, ErrDataTooLong
+	}
+	if chunk <= 0 || chunk > maxChunk {
+		chunk = maxChunk
+	}
+	if len(payload) > MaxSegmentedLen {
+		return nil, ErrSegmentTooLong
+	}
+	var frames [][]byte
+	for _, seg := range Segment(payload, chunk) {
+		f := tmpl
+		f.Kind = KindVariable
+		f.Data = append(append([]byte(nil), prefix...), seg...)
+		b, err := f.Encode()
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, b)
+	}
+	return frames, nil
+}
+
+// Reassembler собирает сегменты в исходное сообщение
+type Reassembler struct {
+	buf     []byte
+	next    int
+	started bool
+	done    bool
+}
+
+// Add принимает очередной сегмент (заголовок + данные). Возвращает true, когда получен FIN
+func (r *Reassembler) Add(seg []byte) (bool, error) {
+	if len(seg) == 0 {
+		return false, ErrFrameTooShort
+	}
+	hdr := seg[0]
+	if hdr&SegmentFIR != 0 {
+		// Новая передача начинается заново, даже если предыдущая не закончена
+		r.buf = r.buf[:0]
+		r.next = 0
+		r.started = true
+		r.done = false
+	}
+	if !r.started || r.done {
+		return false, fmt.Errorf("%w: no first segment", ErrSegmentOrder)
+	}
+	if seq := int(hdr & SegmentSeqMask); seq != r.next {
+		return false, fmt.Errorf("%w: got seq %d, want %d", ErrSegmentOrder, seq, r.next)
+	}
+	if len(r.buf)+len(seg)-1 > MaxSegmentedLen {
+		return false, ErrSegmentTooLong
+	}
+	r.buf = append(r.buf, seg[1:]...)
+	r.next = (r.next + 1) & SegmentSeqMask
+	r.done = hdr&SegmentFIN != 0
+	return r.done, nil
+}
+
+// Bytes возвращает собранное сообщение
+func (r *Reassembler) Bytes() []byte { return r.buf }
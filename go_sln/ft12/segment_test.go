@@ -0,0 +1,179 @@
+package ft12
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func pattern(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestSegmentHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		chunk   int
+		want    []byte // заголовки сегментов
+	}{
+		{"empty", nil, 10, []byte{SegmentFIR | SegmentFIN}},
+		{"single", pattern(10), 10, []byte{SegmentFIR | SegmentFIN}},
+		{"two", pattern(11), 10, []byte{SegmentFIR, SegmentFIN | 1}},
+		{"three", pattern(25), 10, []byte{SegmentFIR, 1, SegmentFIN | 2}},
+		{"chunk below 1", pattern(2), 0, []byte{SegmentFIR, SegmentFIN | 1}},
+	}
+	for _, tt := range tests {
+		segs := Segment(tt.payload, tt.chunk)
+		var hdrs, data []byte
+		for _, s := range segs {
+			hdrs = append(hdrs, s[0])
+			data = append(data, s[1:]...)
+		}
+		if !bytes.Equal(hdrs, tt.want) {
+			t.Errorf("%s: headers % X, want % X", tt.name, hdrs, tt.want)
+		}
+		if !bytes.Equal(data, tt.payload) {
+			t.Errorf("%s: concatenated data differs from payload", tt.name)
+		}
+	}
+	// Номер сегмента идёт по модулю 64
+	segs := Segment(pattern(70), 1)
+	if hdr := segs[64][0]; hdr != 0 {
+		t.Errorf("segment 64 header 0x%02X, want 0x00", hdr)
+	}
+}
+
+// Многокадровая передача собирается Reassembler обратно в исходное сообщение
+func TestEncodeSegmentedRoundTrip(t *testing.T) {
+	prefix := []byte{0x02}
+	for _, tt := range []struct {
+		size, chunk, frames int
+	}{
+		{0, 0, 1},
+		{100, 0, 1},
+		{MaxDataLen - 2, 0, 1},
+		{MaxDataLen - 1, 0, 2},
+		{1024, 0, 5},
+		{1024, 100, 11},
+		{300, 1000, 2}, // chunk больше, чем помещается в LEN, - берётся максимум
+		{5000, 64, 79}, // номер сегмента переходит через 63
+	} {
+		payload := pattern(tt.size)
+		for _, d := range []Dialect{DialectSimplified, DialectFT12} {
+			c := Codec{Dialect: d}
+			tmpl := Frame{Control: 0x08, Address: 0x01, ChecksumKind: ChecksumCRC16, Dialect: d}
+			frames, err := EncodeSegmented(tmpl, prefix, payload, tt.chunk)
+			if err != nil {
+				t.Fatalf("%d/%d %s: EncodeSegmented: %v", tt.size, tt.chunk, d, err)
+			}
+			if len(frames) != tt.frames {
+				t.Errorf("%d/%d %s: %d frame(s), want %d", tt.size, tt.chunk, d, len(frames), tt.frames)
+			}
+			var r Reassembler
+			for i, b := range frames {
+				f, err := c.Decode(b)
+				if err != nil {
+					t.Fatalf("%d/%d %s: frame %d: Decode: %v", tt.size, tt.chunk, d, i, err)
+				}
+				if !bytes.HasPrefix(f.Data, prefix) {
+					t.Fatalf("frame %d: DATA % X does not start with the prefix", i, f.Data)
+				}
+				fin, err := r.Add(f.Data[len(prefix):])
+				if err != nil {
+					t.Fatalf("%d/%d %s: frame %d: Add: %v", tt.size, tt.chunk, d, i, err)
+				}
+				if fin != (i == len(frames)-1) {
+					t.Fatalf("%d/%d %s: frame %d: fin = %v", tt.size, tt.chunk, d, i, fin)
+				}
+			}
+			if !bytes.Equal(r.Bytes(), payload) {
+				t.Errorf("%d/%d %s: reassembled %d byte(s), want %d", tt.size, tt.chunk, d, len(r.Bytes()), len(payload))
+			}
+		}
+	}
+}
+
+func TestEncodeSegmentedErrors(t *testing.T) {
+	tmpl := Frame{Control: 0x08, Address: 0x01}
+	if _, err := EncodeSegmented(tmpl, make([]byte, MaxDataLen), nil, 0); !errors.Is(err, ErrDataTooLong) {
+		t.Errorf("prefix fills LEN: %v, want ErrDataTooLong", err)
+	}
+	if _, err := EncodeSegmented(tmpl, nil, make([]byte, MaxSegmentedLen+1), 0); !errors.Is(err, ErrSegmentTooLong) {
+		t.Errorf("oversized payload: %v, want ErrSegmentTooLong", err)
+	}
+}
+
+// Одиночный кадр длиннее LEN не собирается - только через EncodeSegmented
+func TestBuildSkeletonDataTooLong(t *testing.T) {
+	if _, err := BuildSkeleton(0x08, 0x01, make([]byte, MaxDataLen)); err != nil {
+		t.Fatalf("MaxDataLen bytes: %v", err)
+	}
+	if _, err := BuildSkeleton(0x08, 0x01, make([]byte, MaxDataLen+1)); !errors.Is(err, ErrDataTooLong) {
+		t.Fatalf("MaxDataLen+1 bytes: %v, want ErrDataTooLong", err)
+	}
+	f := Frame{Control: 0x08, Address: 0x01, Data: make([]byte, 300), Dialect: DialectFT12}
+	if _, err := f.Encode(); !errors.Is(err, ErrDataTooLong) {
+		t.Fatalf("Encode 300 bytes: %v, want ErrDataTooLong", err)
+	}
+}
+
+func TestReassemblerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		segs [][]byte
+		want error
+	}{
+		{"empty segment", [][]byte{{}}, ErrFrameTooShort},
+		{"no FIR", [][]byte{{0x00, 1}}, ErrSegmentOrder},
+		{"gap", [][]byte{{SegmentFIR, 1}, {0x02, 2}}, ErrSegmentOrder},
+		{"repeat", [][]byte{{SegmentFIR, 1}, {0x01, 2}, {0x01, 2}}, ErrSegmentOrder},
+		{"after FIN", [][]byte{{SegmentFIR | SegmentFIN, 1}, {0x01, 2}}, ErrSegmentOrder},
+		{"FIN without FIR", [][]byte{{SegmentFIN | 1, 1}}, ErrSegmentOrder},
+	}
+	for _, tt := range tests {
+		var r Reassembler
+		var err error
+		for _, s := range tt.segs {
+			if _, err = r.Add(s); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// FIR начинает передачу заново, даже если предыдущая не закончена
+func TestReassemblerRestart(t *testing.T) {
+	var r Reassembler
+	if _, err := r.Add([]byte{SegmentFIR, 1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	fin, err := r.Add([]byte{SegmentFIR | SegmentFIN, 9})
+	if err != nil || !fin {
+		t.Fatalf("Add = %v, %v", fin, err)
+	}
+	if !bytes.Equal(r.Bytes(), []byte{9}) {
+		t.Errorf("Bytes = % X, want 09", r.Bytes())
+	}
+}
+
+func TestReassemblerTooLong(t *testing.T) {
+	var r Reassembler
+	chunk := make([]byte, 1+MaxDataLen)
+	chunk[0] = SegmentFIR
+	var err error
+	for i := 0; err == nil && i < MaxSegmentedLen/MaxDataLen+2; i++ {
+		_, err = r.Add(chunk)
+		chunk[0] = byte(i+1) & SegmentSeqMask
+	}
+	if !errors.Is(err, ErrSegmentTooLong) {
+		t.Fatalf("error %v, want ErrSegmentTooLong", err)
+	}
+}
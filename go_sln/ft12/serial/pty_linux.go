@@ -0,0 +1,57 @@
+//go:build linux
+
+package serial
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// OpenPTY создаёт пару псевдотерминалов. Ведущая сторона возвращается как Port, ведомая
+// (/dev/pts/N, см. PTY.Name) открывается внешней программой как обычный последовательный порт.
+// Ведомой стороне задаются «сырой» режим и параметры m; сам псевдотерминал скорость не
+// ограничивает, темп линии при необходимости задаёт Paced
+func OpenPTY(m Mode) (*PTY, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	fd, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: "/dev/ptmx", Err: err}
+	}
+	name, err := ptsName(fd)
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+	// termios ведомой стороны меняется и через ведущую; без этого эхо терминала вернёт
+	// эмулятору его же ответы
+	if err := configure(fd, m); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	// Держим ведомую сторону открытой: иначе после закрытия порта внешней программой
+	// чтение ведущей стороны возвращает EIO до следующего открытия
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+	return &PTY{Port: &Port{File: os.NewFile(uintptr(fd), name), path: name}, Name: name, slave: slave}, nil
+}
+
+// ptsName разблокирует ведомую сторону (unlockpt) и возвращает её путь (ptsname)
+func ptsName(fd int) (string, error) {
+	var unlock int32
+	if err := ioctl(fd, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		return "", fmt.Errorf("TIOCSPTLCK: %w", err)
+	}
+	var n uint32
+	if err := ioctl(fd, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		return "", fmt.Errorf("TIOCGPTN: %w", err)
+	}
+	return "/dev/pts/" + strconv.FormatUint(uint64(n), 10), nil
+}
@@ -0,0 +1,139 @@
+// Package serial открывает последовательный порт (RS-232/RS-485 через USB-адаптер) как net.Conn,
+// чтобы клиент и эмулятор работали с ним так же, как с TCP-соединением: через декодер,
+// с таймаутами на SetReadDeadline и с записью обмена.
+//
+// Настройка линии (скорость, биты данных, чётность, стоп-биты) выполняется ioctl termios
+// напрямую через syscall, без cgo. OpenPTY создаёт пару псевдотерминалов, чтобы эмулятор
+// выглядел для внешних программ как последовательный порт. Сейчас поддерживается только Linux.
+package serial
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Parity - контроль чётности
+type Parity byte
+
+const (
+	ParityNone Parity = 'N'
+	ParityEven Parity = 'E' // FT1.2 (IEC 60870-5-1) использует 8E1
+	ParityOdd  Parity = 'O'
+)
+
+// ParseParity разбирает N | E | O (регистр не важен)
+func ParseParity(s string) (Parity, error) {
+	if len(s) == 1 {
+		switch p := Parity(strings.ToUpper(s)[0]); p {
+		case ParityNone, ParityEven, ParityOdd:
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown parity %q (want N | E | O)", s)
+}
+
+// Mode - параметры линии
+type Mode struct {
+	Baud     int
+	DataBits int // 5..8
+	Parity   Parity
+	StopBits int // 1 или 2
+}
+
+// Validate проверяет параметры (скорость - по таблице поддерживаемых платформой)
+func (m Mode) Validate() error {
+	if _, ok := baudRates[m.Baud]; !ok {
+		return fmt.Errorf("unsupported baud rate %d", m.Baud)
+	}
+	if m.DataBits < 5 || m.DataBits > 8 {
+		return fmt.Errorf("data bits %d out of range 5..8", m.DataBits)
+	}
+	switch m.Parity {
+	case ParityNone, ParityEven, ParityOdd:
+	default:
+		return fmt.Errorf("unknown parity %q (want N | E | O)", string(m.Parity))
+	}
+	if m.StopBits != 1 && m.StopBits != 2 {
+		return fmt.Errorf("stop bits %d: want 1 or 2", m.StopBits)
+	}
+	return nil
+}
+
+// String - краткая запись "9600 8E1"
+func (m Mode) String() string {
+	return fmt.Sprintf("%d %d%c%d", m.Baud, m.DataBits, m.Parity, m.StopBits)
+}
+
+// CharTime - время передачи одного символа: старт + данные + чётность + стоп
+func (m Mode) CharTime() time.Duration {
+	bits := 1 + m.DataBits + m.StopBits
+	if m.Parity != ParityNone {
+		bits++
+	}
+	return time.Duration(bits) * time.Second / time.Duration(m.Baud)
+}
+
+// Port - открытый последовательный порт. Реализует net.Conn; адрес - путь к устройству
+type Port struct {
+	*os.File
+	path string
+}
+
+// Addr - "адрес" последовательного порта для логов и записи обмена
+type Addr string
+
+func (a Addr) Network() string { return "serial" }
+func (a Addr) String() string  { return string(a) }
+
+func (p *Port) LocalAddr() net.Addr  { return Addr(p.path) }
+func (p *Port) RemoteAddr() net.Addr { return Addr(p.path) }
+
+var _ net.Conn = (*Port)(nil)
+
+// PTY - ведущая сторона псевдотерминала; Name - путь ведомой стороны для внешней программы
+type PTY struct {
+	*Port
+	Name  string
+	slave *os.File // своя копия ведомой стороны, см. OpenPTY
+}
+
+// Close закрывает обе стороны; после этого ведомая сторона исчезает из /dev/pts
+func (p *PTY) Close() error {
+	_ = p.slave.Close()
+	return p.Port.Close()
+}
+
+// Paced ограничивает запись в c скоростью линии m: байты уходят по одному с интервалом
+// CharTime, как из UART. Нужен там, где сам канал скорость не ограничивает (псевдотерминал)
+func Paced(c net.Conn, m Mode) net.Conn {
+	return &pacedConn{Conn: c, char: m.CharTime()}
+}
+
+type pacedConn struct {
+	net.Conn
+	char time.Duration
+
+	mu   sync.Mutex
+	free time.Time // когда «линия» освободится после уже отправленных байтов
+}
+
+func (p *pacedConn) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now := time.Now(); p.free.Before(now) {
+		p.free = now
+	}
+	for i := range b {
+		// Ждём до расчётного момента, а не фиксированную паузу: ошибка таймера не копится
+		time.Sleep(time.Until(p.free))
+		if _, err := p.Conn.Write(b[i : i+1]); err != nil {
+			return i, err
+		}
+		p.free = p.free.Add(p.char)
+	}
+	return len(b), nil
+}
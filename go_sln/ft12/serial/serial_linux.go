@@ -0,0 +1,111 @@
+//go:build linux
+
+package serial
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// baudRates - стандартные скорости termios
+var baudRates = map[int]uint32{
+	300: syscall.B300, 600: syscall.B600, 1200: syscall.B1200, 2400: syscall.B2400,
+	4800: syscall.B4800, 9600: syscall.B9600, 19200: syscall.B19200, 38400: syscall.B38400,
+	57600: syscall.B57600, 115200: syscall.B115200, 230400: syscall.B230400,
+	460800: syscall.B460800, 921600: syscall.B921600,
+}
+
+// cbaud - маска битов скорости в c_cflag. В syscall её нет, а значение зависит от архитектуры,
+// поэтому собираем её из самих констант скорости
+var cbaud = func() uint32 {
+	var m uint32
+	for _, b := range baudRates {
+		m |= b
+	}
+	return m | syscall.B4000000
+}()
+
+// Open открывает устройство (например /dev/ttyUSB0) в «сыром» режиме с параметрами m
+// и монопольным доступом (TIOCEXCL). Порт неблокирующий, поэтому SetReadDeadline работает
+func Open(path string, m Mode) (*Port, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	if err := configure(fd, m); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := ioctl(fd, syscall.TIOCEXCL, nil); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("%s: TIOCEXCL: %w", path, err)
+	}
+	// os.NewFile регистрирует неблокирующий дескриптор в поллере рантайма
+	return &Port{File: os.NewFile(uintptr(fd), path), path: path}, nil
+}
+
+// Configure меняет параметры линии уже открытого порта
+func (p *Port) Configure(m Mode) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	rc, err := p.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cerr error
+	if err := rc.Control(func(fd uintptr) { cerr = configure(int(fd), m) }); err != nil {
+		return err
+	}
+	return cerr
+}
+
+// configure переводит терминал в «сырой» режим (как cfmakeraw) и задаёт параметры линии
+func configure(fd int, m Mode) error {
+	var t syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		return fmt.Errorf("TCGETS: %w", err)
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.INPCK
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.PARODD | syscall.CSTOPB | cbaud
+	t.Cflag |= syscall.CREAD | syscall.CLOCAL
+
+	t.Cflag |= map[int]uint32{5: syscall.CS5, 6: syscall.CS6, 7: syscall.CS7, 8: syscall.CS8}[m.DataBits]
+	switch m.Parity {
+	case ParityEven:
+		t.Cflag |= syscall.PARENB
+		t.Iflag |= syscall.INPCK
+	case ParityOdd:
+		t.Cflag |= syscall.PARENB | syscall.PARODD
+		t.Iflag |= syscall.INPCK
+	}
+	if m.StopBits == 2 {
+		t.Cflag |= syscall.CSTOPB
+	}
+	speed := baudRates[m.Baud]
+	t.Cflag |= speed
+	t.Ispeed, t.Ospeed = speed, speed
+	// Read возвращает всё, что пришло, не дожидаясь заполнения буфера; таймауты - через поллер
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&t)); err != nil {
+		return fmt.Errorf("TCSETS: %w", err)
+	}
+	return nil
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+var mode8E1 = Mode{Baud: 9600, DataBits: 8, Parity: ParityEven, StopBits: 1}
+
+// openPair открывает псевдотерминал и его ведомую сторону через Open, как клиент открывает
+// настоящий порт
+func openPair(t *testing.T) (*PTY, *Port) {
+	t.Helper()
+	pt, err := OpenPTY(mode8E1)
+	if err != nil {
+		t.Skipf("pseudo-terminal unavailable: %v", err)
+	}
+	t.Cleanup(func() { _ = pt.Close() })
+	port, err := Open(pt.Name, mode8E1)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", pt.Name, err)
+	}
+	t.Cleanup(func() { _ = port.Close() })
+	return pt, port
+}
+
+func readFull(t *testing.T, r interface {
+	io.Reader
+	SetReadDeadline(time.Time) error
+}, n int) []byte {
+	t.Helper()
+	if err := r.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("SetReadDeadline: %v", err)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	return buf
+}
+
+// В «сыром» режиме все 256 значений байта проходят без преобразований в обе стороны:
+// никаких CR/LF, XON/XOFF, сигналов по ^C и эха
+func TestRawRoundTrip(t *testing.T) {
+	pt, port := openPair(t)
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+
+	if _, err := pt.Write(all); err != nil {
+		t.Fatalf("pty write: %v", err)
+	}
+	if got := readFull(t, port, len(all)); !bytes.Equal(got, all) {
+		t.Fatalf("pty -> port:\n got % X\nwant % X", got, all)
+	}
+
+	if _, err := port.Write(all); err != nil {
+		t.Fatalf("port write: %v", err)
+	}
+	if got := readFull(t, pt, len(all)); !bytes.Equal(got, all) {
+		t.Fatalf("port -> pty:\n got % X\nwant % X", got, all)
+	}
+	// Эха нет: на стороне порта не осталось отправленных им байтов
+	_ = port.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, err := port.Read(make([]byte, 1)); n != 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("unexpected echo: n=%d err=%v", n, err)
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	_, port := openPair(t)
+	const timeout = 100 * time.Millisecond
+	start := time.Now()
+	_ = port.SetReadDeadline(start.Add(timeout))
+	n, err := port.Read(make([]byte, 16))
+	elapsed := time.Since(start)
+	if n != 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read = %d, %v; want deadline exceeded", n, err)
+	}
+	if elapsed < timeout || elapsed > 10*timeout {
+		t.Fatalf("Read returned after %s, deadline %s", elapsed, timeout)
+	}
+}
+
+// Paced выдаёт байты с интервалом времени символа: 23 байта при 9600 8E1 - не меньше 22 интервалов
+func TestPaced(t *testing.T) {
+	pt, port := openPair(t)
+	frame := []byte{0x68, 0x11, 0x68, 0x88, 0x01, 0x01, 0x32, 0x30, 0x32, 0x36, 0x2D, 0x31, 0x30,
+		0x2D, 0x31, 0x37, 0x20, 0x31, 0x35, 0x3A, 0x34, 0x3C, 0x16}
+	paced := Paced(pt, mode8E1)
+
+	start := time.Now()
+	if _, err := paced.Write(frame); err != nil {
+		t.Fatalf("paced write: %v", err)
+	}
+	elapsed := time.Since(start)
+	if want := time.Duration(len(frame)-1) * mode8E1.CharTime(); elapsed < want {
+		t.Fatalf("%d bytes written in %s, want at least %s", len(frame), elapsed, want)
+	}
+	if got := readFull(t, port, len(frame)); !bytes.Equal(got, frame) {
+		t.Fatalf("paced bytes:\n got % X\nwant % X", got, frame)
+	}
+}
+
+func TestCharTime(t *testing.T) {
+	tests := []struct {
+		m    Mode
+		want time.Duration
+	}{
+		{mode8E1, 11 * time.Second / 9600},
+		{Mode{Baud: 19200, DataBits: 8, Parity: ParityNone, StopBits: 1}, 10 * time.Second / 19200},
+		{Mode{Baud: 1200, DataBits: 7, Parity: ParityOdd, StopBits: 2}, 11 * time.Second / 1200},
+	}
+	for _, tt := range tests {
+		if got := tt.m.CharTime(); got != tt.want {
+			t.Errorf("%s: CharTime = %s, want %s", tt.m, got, tt.want)
+		}
+	}
+}
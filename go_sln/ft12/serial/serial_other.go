@@ -0,0 +1,32 @@
+//go:build !linux
+
+package serial
+
+import (
+	"errors"
+	"runtime"
+)
+
+// ErrUnsupported - последовательный порт на этой платформе не реализован
+var ErrUnsupported = errors.New("serial port is not supported on " + runtime.GOOS)
+
+// baudRates - на других платформах список совпадает с Linux, чтобы Validate вёл себя одинаково
+var baudRates = map[int]uint32{
+	300: 0, 600: 0, 1200: 0, 2400: 0, 4800: 0, 9600: 0, 19200: 0, 38400: 0,
+	57600: 0, 115200: 0, 230400: 0, 460800: 0, 921600: 0,
+}
+
+// Open на этой платформе не поддерживается
+func Open(path string, m Mode) (*Port, error) {
+	return nil, ErrUnsupported
+}
+
+// Configure на этой платформе не поддерживается
+func (p *Port) Configure(m Mode) error {
+	return ErrUnsupported
+}
+
+// OpenPTY на этой платформе не поддерживается
+func OpenPTY(m Mode) (*PTY, error) {
+	return nil, ErrUnsupported
+}
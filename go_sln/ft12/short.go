@@ -0,0 +1,41 @@
+package ft12
+
+const (
+	// SingleCharAck - односимвольное положительное подтверждение FT1.2
+	SingleCharAck = 0xE5
+	// ShortFrameLen - длина короткого кадра 0x10 C A CS 0x16
+	ShortFrameLen = 5
+)
+
+// BuildShort формирует короткий кадр фиксированной длины.
+// Контрольная сумма короткого кадра всегда 1-байтная: сумма CONTROL и ADDR
+func BuildShort(control byte, addr byte) []byte {
+	return []byte{0x10, control, addr, control + addr, 0x16}
+}
+
+// verifyShort проверяет структуру и контрольную сумму короткого кадра
+func verifyShort(frame []byte) error {
+	if len(frame) < ShortFrameLen {
+		return ErrFrameTooShort
+	}
+	if len(frame) != ShortFrameLen {
+		return ErrLengthMismatch
+	}
+	if frame[ShortFrameLen-1] != 0x16 {
+		return ErrNoEndByte
+	}
+	if frame[3] != ComputeSum(frame[1:3]) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// indexStart ищет первый стартовый байт любого вида кадра (0x68, 0x10 или 0xE5)
+func indexStart(b []byte) int {
+	for i, v := range b {
+		if v == 0x68 || v == 0x10 || v == SingleCharAck {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,172 @@
+package ft12
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Форматы времени устройства в DATA ответов (см. LookupTimeCodec)
+const (
+	TimeASCII = "ascii" // ASCII "YYYY-MM-DD HH:MM:SS", 19 байт
+	TimeCP56  = "cp56"  // CP56Time2a (IEC 60870-5-4), 7 байт
+	TimeBCD   = "bcd"   // BCD: SS MM HH DD MM YY, 6 байт
+)
+
+// asciiTimeLayout - исторический формат времени эмулятора
+const asciiTimeLayout = "2006-01-02 15:04:05"
+
+var ErrBadTime = &FrameError{"bad time encoding"}
+
+// DeviceTime - показания часов устройства вместе с флагами качества
+type DeviceTime struct {
+	Time    time.Time
+	Invalid bool // IV: часы устройства недостоверны
+	Summer  bool // SU: действует летнее время
+}
+
+// TimeCodec кодирует и разбирает время устройства в одном из форматов.
+// Флаги и миллисекунды передаются только там, где формат их поддерживает
+type TimeCodec interface {
+	// Size возвращает длину закодированного времени в байтах
+	Size() int
+	Encode(t DeviceTime) []byte
+	Decode(b []byte) (DeviceTime, error)
+}
+
+var timeCodecs = map[string]TimeCodec{
+	TimeASCII: asciiTime{},
+	TimeCP56:  cp56Time{},
+	TimeBCD:   bcdTime{},
+}
+
+// LookupTimeCodec возвращает кодек времени по имени; неизвестное имя - ошибка
+func LookupTimeCodec(name string) (TimeCodec, error) {
+	if tc, ok := timeCodecs[name]; ok {
+		return tc, nil
+	}
+	return nil, fmt.Errorf("unknown time format %q (want %s)", name, strings.Join(TimeCodecNames(), " | "))
+}
+
+// TimeCodecNames возвращает отсортированный список форматов времени
+func TimeCodecNames() []string {
+	out := make([]string, 0, len(timeCodecs))
+	for k := range timeCodecs {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// asciiTime - "YYYY-MM-DD HH:MM:SS" в местном времени, без миллисекунд и флагов
+type asciiTime struct{}
+
+func (asciiTime) Size() int { return len(asciiTimeLayout) }
+
+func (asciiTime) Encode(t DeviceTime) []byte {
+	return []byte(t.Time.Format(asciiTimeLayout))
+}
+
+func (asciiTime) Decode(b []byte) (DeviceTime, error) {
+	t, err := time.ParseInLocation(asciiTimeLayout, string(b), time.Local)
+	if err != nil {
+		return DeviceTime{}, fmt.Errorf("%w: %v", ErrBadTime, err)
+	}
+	return DeviceTime{Time: t}, nil
+}
+
+// cp56Time - CP56Time2a:
+//
+//	байты 0-1: миллисекунды 0..59999 (little-endian)
+//	байт 2:    минуты (bit0-5), IV (bit7)
+//	байт 3:    часы (bit0-4), SU (bit7)
+//	байт 4:    день месяца (bit0-4), день недели 1..7 = пн..вс (bit5-7)
+//	байт 5:    месяц (bit0-3)
+//	байт 6:    год 0..99 от 2000 (bit0-6)
+type cp56Time struct{}
+
+func (cp56Time) Size() int { return 7 }
+
+func (cp56Time) Encode(t DeviceTime) []byte {
+	tm := t.Time
+	ms := tm.Second()*1000 + tm.Nanosecond()/int(time.Millisecond)
+	b := make([]byte, 7)
+	b[0] = byte(ms)
+	b[1] = byte(ms >> 8)
+	b[2] = byte(tm.Minute()) & 0x3F
+	if t.Invalid {
+		b[2] |= 0x80
+	}
+	b[3] = byte(tm.Hour()) & 0x1F
+	if t.Summer {
+		b[3] |= 0x80
+	}
+	wd := int(tm.Weekday())
+	if wd == 0 {
+		wd = 7 // воскресенье
+	}
+	b[4] = byte(tm.Day())&0x1F | byte(wd)<<5
+	b[5] = byte(tm.Month()) & 0x0F
+	b[6] = byte(tm.Year()%100) & 0x7F
+	return b
+}
+
+func (cp56Time) Decode(b []byte) (DeviceTime, error) {
+	if len(b) != 7 {
+		return DeviceTime{}, fmt.Errorf("%w: cp56 needs 7 bytes, got %d", ErrBadTime, len(b))
+	}
+	ms := int(b[0]) | int(b[1])<<8
+	minute := int(b[2] & 0x3F)
+	hour := int(b[3] & 0x1F)
+	day := int(b[4] & 0x1F)
+	month := int(b[5] & 0x0F)
+	year := 2000 + int(b[6]&0x7F)
+	if ms > 59999 || minute > 59 || hour > 23 || day < 1 || day > 31 || month < 1 || month > 12 {
+		return DeviceTime{}, fmt.Errorf("%w: cp56 % X out of range", ErrBadTime, b)
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, ms/1000, (ms%1000)*int(time.Millisecond), time.Local)
+	return DeviceTime{Time: t, Invalid: b[2]&0x80 != 0, Summer: b[3]&0x80 != 0}, nil
+}
+
+// bcdTime - SS MM HH DD MM YY в двоично-десятичном коде, год от 2000
+type bcdTime struct{}
+
+func (bcdTime) Size() int { return 6 }
+
+func (bcdTime) Encode(t DeviceTime) []byte {
+	tm := t.Time
+	return []byte{
+		toBCD(tm.Second()), toBCD(tm.Minute()), toBCD(tm.Hour()),
+		toBCD(tm.Day()), toBCD(int(tm.Month())), toBCD(tm.Year() % 100),
+	}
+}
+
+func (bcdTime) Decode(b []byte) (DeviceTime, error) {
+	if len(b) != 6 {
+		return DeviceTime{}, fmt.Errorf("%w: bcd needs 6 bytes, got %d", ErrBadTime, len(b))
+	}
+	var v [6]int
+	for i, x := range b {
+		d, ok := fromBCD(x)
+		if !ok {
+			return DeviceTime{}, fmt.Errorf("%w: bad bcd digit 0x%02X", ErrBadTime, x)
+		}
+		v[i] = d
+	}
+	if v[0] > 59 || v[1] > 59 || v[2] > 23 || v[3] < 1 || v[3] > 31 || v[4] < 1 || v[4] > 12 {
+		return DeviceTime{}, fmt.Errorf("%w: bcd % X out of range", ErrBadTime, b)
+	}
+	t := time.Date(2000+v[5], time.Month(v[4]), v[3], v[2], v[1], v[0], 0, time.Local)
+	return DeviceTime{Time: t}, nil
+}
+
+func toBCD(v int) byte { return byte(v/10)<<4 | byte(v%10) }
+
+func fromBCD(b byte) (int, bool) {
+	hi, lo := b>>4, b&0x0F
+	if hi > 9 || lo > 9 {
+		return 0, false
+	}
+	return int(hi)*10 + int(lo), true
+}
@@ -0,0 +1,102 @@
+package ft12
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Суббота 17.10.2026 13:25:10.773
+var sampleTime = time.Date(2026, time.October, 17, 13, 25, 10, 773*int(time.Millisecond), time.Local)
+
+func TestTimeCodecEncode(t *testing.T) {
+	tests := []struct {
+		format string
+		in     DeviceTime
+		want   []byte
+	}{
+		{TimeASCII, DeviceTime{Time: sampleTime}, []byte("2026-10-17 13:25:10")},
+		{TimeCP56, DeviceTime{Time: sampleTime}, []byte{0x15, 0x2A, 0x19, 0x0D, 0xD1, 0x0A, 0x1A}},
+		{TimeCP56, DeviceTime{Time: sampleTime, Invalid: true, Summer: true}, []byte{0x15, 0x2A, 0x99, 0x8D, 0xD1, 0x0A, 0x1A}},
+		// Воскресенье - день недели 7
+		{TimeCP56, DeviceTime{Time: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.Local)}, []byte{0x00, 0x00, 0x00, 0x00, 0xF2, 0x0A, 0x1A}},
+		{TimeBCD, DeviceTime{Time: sampleTime}, []byte{0x10, 0x25, 0x13, 0x17, 0x10, 0x26}},
+	}
+	for _, tt := range tests {
+		tc, err := LookupTimeCodec(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tc.Encode(tt.in)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: Encode(%+v) = % X, want % X", tt.format, tt.in, got, tt.want)
+		}
+		if len(got) != tc.Size() {
+			t.Errorf("%s: %d byte(s), Size() = %d", tt.format, len(got), tc.Size())
+		}
+	}
+}
+
+// Decode(Encode(t)) возвращает время с точностью формата: секунды у ascii и bcd,
+// миллисекунды и флаги у cp56
+func TestTimeCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		format string
+		in     DeviceTime
+		want   DeviceTime
+	}{
+		{TimeASCII, DeviceTime{Time: sampleTime, Summer: true}, DeviceTime{Time: sampleTime.Truncate(time.Second)}},
+		{TimeCP56, DeviceTime{Time: sampleTime, Invalid: true, Summer: true}, DeviceTime{Time: sampleTime, Invalid: true, Summer: true}},
+		{TimeCP56, DeviceTime{Time: sampleTime.Add(time.Microsecond)}, DeviceTime{Time: sampleTime}},
+		{TimeBCD, DeviceTime{Time: sampleTime, Invalid: true}, DeviceTime{Time: sampleTime.Truncate(time.Second)}},
+	}
+	for _, tt := range tests {
+		tc, _ := LookupTimeCodec(tt.format)
+		got, err := tc.Decode(tc.Encode(tt.in))
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tt.format, err)
+		}
+		if !got.Time.Equal(tt.want.Time) || got.Invalid != tt.want.Invalid || got.Summer != tt.want.Summer {
+			t.Errorf("%s: round trip = %+v, want %+v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestTimeCodecDecodeErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		in     []byte
+	}{
+		{TimeASCII, []byte("2026-10-17")},
+		{TimeASCII, []byte("2026-13-17 13:25:10")},
+		{TimeCP56, []byte{0x15, 0x2A, 0x19, 0x0D, 0xD1, 0x0A}},
+		{TimeCP56, []byte{0x60, 0xEA, 0x19, 0x0D, 0xD1, 0x0A, 0x1A}}, // 60000 мс
+		{TimeCP56, []byte{0x15, 0x2A, 0x3C, 0x0D, 0xD1, 0x0A, 0x1A}}, // минута 60
+		{TimeCP56, []byte{0x15, 0x2A, 0x19, 0x18, 0xD1, 0x0A, 0x1A}}, // час 24
+		{TimeCP56, []byte{0x15, 0x2A, 0x19, 0x0D, 0xC0, 0x0A, 0x1A}}, // день 0
+		{TimeCP56, []byte{0x15, 0x2A, 0x19, 0x0D, 0xD1, 0x0D, 0x1A}}, // месяц 13
+		{TimeBCD, []byte{0x10, 0x25, 0x13, 0x17, 0x10}},
+		{TimeBCD, []byte{0x10, 0x25, 0x13, 0x17, 0x1A, 0x26}}, // не BCD
+		{TimeBCD, []byte{0x60, 0x25, 0x13, 0x17, 0x10, 0x26}}, // секунда 60
+		{TimeBCD, []byte{0x10, 0x25, 0x13, 0x00, 0x10, 0x26}}, // день 0
+		{TimeBCD, []byte{0x10, 0x25, 0x13, 0x17, 0x13, 0x26}}, // месяц 13
+	}
+	for _, tt := range tests {
+		tc, _ := LookupTimeCodec(tt.format)
+		if _, err := tc.Decode(tt.in); !errors.Is(err, ErrBadTime) {
+			t.Errorf("%s: Decode(% X) error = %v, want ErrBadTime", tt.format, tt.in, err)
+		}
+	}
+}
+
+func TestLookupTimeCodec(t *testing.T) {
+	for _, name := range TimeCodecNames() {
+		if _, err := LookupTimeCodec(name); err != nil {
+			t.Errorf("LookupTimeCodec(%q): %v", name, err)
+		}
+	}
+	if _, err := LookupTimeCodec("unix"); err == nil {
+		t.Errorf("LookupTimeCodec(unix): no error")
+	}
+}
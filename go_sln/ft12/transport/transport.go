@@ -0,0 +1,117 @@
+// Package transport - каналы связи с прибором: TCP, UDP, unix-сокет, последовательный порт
+// и TLS поверх TCP или unix-сокета. Общий для клиента и прокси эмулятора
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sln/ft12/serial"
+	"strings"
+	"time"
+)
+
+// Виды транспорта (-transport)
+const (
+	KindTCP    = "tcp"
+	KindUDP    = "udp"
+	KindUnix   = "unix"
+	KindSerial = "serial"
+)
+
+// Transport открывает канал связи с прибором. Результат - net.Conn, поэтому декодер,
+// таймауты и запись обмена работают одинаково для любого транспорта
+type Transport interface {
+	Dial(timeout time.Duration) (net.Conn, error)
+	// String - адрес или устройство для логов
+	String() string
+}
+
+// Parse разбирает адрес вида [вид:]адрес: host:port или tcp:host:port, udp:host:port,
+// unix:/path/to.sock, serial:/dev/ttyUSB0. Без вида адрес считается TCP.
+// mode - параметры линии для последовательного порта
+func Parse(addr string, mode serial.Mode) (Transport, error) {
+	kind, rest := KindTCP, addr
+	if i := strings.IndexByte(addr, ':'); i > 0 {
+		switch k := addr[:i]; k {
+		case KindTCP, KindUDP, KindUnix, KindSerial:
+			kind, rest = k, addr[i+1:]
+		}
+	}
+	switch kind {
+	case KindTCP, KindUDP:
+		if _, _, err := net.SplitHostPort(rest); err != nil {
+			return nil, err
+		}
+		return Net{Network: kind, Addr: rest}, nil
+	case KindUnix:
+		if rest == "" {
+			return nil, fmt.Errorf("%q: empty socket path", addr)
+		}
+		return Net{Network: KindUnix, Addr: rest}, nil
+	default:
+		if rest == "" {
+			return nil, fmt.Errorf("%q: empty device", addr)
+		}
+		if err := mode.Validate(); err != nil {
+			return nil, err
+		}
+		return Serial{Device: rest, Mode: mode}, nil
+	}
+}
+
+// Net - подключение к эмулятору или преобразователю интерфейсов по TCP, UDP или unix-сокету.
+// По UDP каждая датаграмма - кусок потока: декодер собирает кадры так же, как из TCP
+type Net struct {
+	Network string // tcp | udp | unix
+	Addr    string
+}
+
+// Dial подключается к Addr; для UDP это только выбор адресата, ответ другой стороны не ждётся
+func (n Net) Dial(timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(n.Network, n.Addr, timeout)
+}
+
+func (n Net) String() string {
+	if n.Network == KindTCP {
+		return n.Addr
+	}
+	return n.Network + ":" + n.Addr
+}
+
+// Serial - последовательный порт (RS-232/RS-485 через USB-адаптер)
+type Serial struct {
+	Device string
+	Mode   serial.Mode
+}
+
+// Dial открывает порт; timeout не нужен - открытие не ждёт другую сторону
+func (s Serial) Dial(timeout time.Duration) (net.Conn, error) {
+	return serial.Open(s.Device, s.Mode)
+}
+
+func (s Serial) String() string { return s.Device + " " + s.Mode.String() }
+
+// TLS - подключение через Inner (tcp или unix) с TLS поверх. Рукопожатие выполняется в Dial,
+// поэтому ошибка сертификата обрабатывается как ошибка подключения (повтор с паузой)
+type TLS struct {
+	Inner  Net
+	Config *tls.Config
+}
+
+func (t TLS) Dial(timeout time.Duration) (net.Conn, error) {
+	conn, err := t.Inner.Dial(timeout)
+	if err != nil {
+		return nil, err
+	}
+	tc := tls.Client(conn, t.Config)
+	_ = tc.SetDeadline(time.Now().Add(timeout))
+	if err := tc.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("tls handshake: %w", err)
+	}
+	_ = tc.SetDeadline(time.Time{})
+	return tc, nil
+}
+
+func (t TLS) String() string { return t.Inner.String() + " (tls)" }
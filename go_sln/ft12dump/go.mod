@@ -0,0 +1,7 @@
+module sln/ft12dump
+
+go 1.25
+
+require sln/ft12 v0.0.0
+
+replace sln/ft12 => ../ft12
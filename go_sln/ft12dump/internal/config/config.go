@@ -0,0 +1,53 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Config хранит параметры разбора записи
+type Config struct {
+	Files      []string   // .pcap или JSONL-запись (-record)
+	Port       int        // TCP/UDP-порт прибора в .pcap
+	CRCMode    string     // ожидаемый алгоритм контрольной суммы
+	CRCDefs    stringList // дополнительные алгоритмы CRC для реестра ft12
+	Dialect    string
+	StrictCRC  bool
+	TimeFormat string // формат времени устройства (ft12.LookupTimeCodec)
+	Hex        bool   // печатать hex-дамп каждого кадра
+}
+
+// Load парсит флаги командной строки и возвращает конфиг
+func Load() *Config {
+	c := &Config{}
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ft12dump [flags] capture.pcap|capture.jsonl ...\n")
+		flag.PrintDefaults()
+	}
+	flag.IntVar(&c.Port, "port", 9000, "device TCP/UDP port: streams to and from it are decoded (pcap only)")
+	flag.StringVar(&c.CRCMode, "crc", "sum", "expected checksum algorithm: sum | crc16 | xor8 | crc8 | crc16-be | crc16-ccitt | crc16-x25 | crc16-kermit | name from -crcdef")
+	flag.StringVar(&c.Dialect, "dialect", "simplified", "frame dialect: simplified (68 L 68) | ft12 (68 L L 68)")
+	flag.BoolVar(&c.StrictCRC, "strict", false, "accept only frames signed with the configured -crc algorithm")
+	flag.StringVar(&c.TimeFormat, "timefmt", "ascii", "time format in read-time / write-time data: ascii | cp56 (CP56Time2a) | bcd")
+	flag.BoolVar(&c.Hex, "hex", true, "print a hex dump under each frame")
+	flag.Var(&c.CRCDefs, "crcdef", "extra CRC definition name:width=16,poly=0x1021,init=0xFFFF,reflect=false,xorout=0,order=be (repeatable)")
+	flag.Parse()
+	c.Files = flag.Args()
+	if len(c.Files) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	return c
+}
+
+// stringList - флаг, который можно указать несколько раз
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, "; ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
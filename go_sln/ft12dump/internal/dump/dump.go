@@ -0,0 +1,75 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sln/ft12"
+	"sln/ft12/capture"
+	"strings"
+)
+
+// Printer разбирает собранные потоки тем же кодеком, что клиент и эмулятор,
+// и печатает кадры с пояснениями
+type Printer struct {
+	Codec     ft12.Codec
+	TimeCodec ft12.TimeCodec
+	Hex       bool
+	W         io.Writer
+}
+
+// Stats - итог по одному потоку
+type Stats struct {
+	Frames    int
+	BadCRC    int
+	Discarded int // байт мусора между кадрами
+	Tail      int // байт незавершённого кадра в конце потока
+}
+
+// Stream печатает все кадры потока n в порядке времени
+func (p *Printer) Stream(n int, st *capture.Stream) Stats {
+	fmt.Fprintf(p.W, "== stream %d: poller %s <-> device %s (%d chunk(s))\n", n, st.Poller, st.Device, len(st.Chunks))
+	if st.Gaps > 0 {
+		fmt.Fprintf(p.W, "   WARNING: %d gap(s) in TCP sequence - segments lost by capture\n", st.Gaps)
+	}
+
+	// Каждое направление собирается в свой буфер, как у декодера на стороне получателя
+	var bufs [2]bytes.Buffer
+	var stats Stats
+	for _, c := range st.Chunks {
+		dir := "P->D"
+		buf := &bufs[0]
+		if c.FromDevice {
+			dir, buf = "D->P", &bufs[1]
+		}
+		buf.Write(c.Data)
+		for {
+			before := buf.Len()
+			frame, kind, ok := p.Codec.ExtractFrame(buf)
+			skipped := before - buf.Len() - len(frame)
+			if skipped > 0 {
+				stats.Discarded += skipped
+				fmt.Fprintf(p.W, "%s  %s  skipped %d garbage byte(s)\n", c.Time.Format(timeLayout), dir, skipped)
+			}
+			if !ok {
+				break
+			}
+			stats.Frames++
+			line, bad := ft12.Annotator{Codec: p.Codec, TimeCodec: p.TimeCodec}.Describe(frame, kind, c.FromDevice)
+			if bad {
+				stats.BadCRC++
+			}
+			fmt.Fprintf(p.W, "%s  %s  %s\n", c.Time.Format(timeLayout), dir, line)
+			if p.Hex && kind != ft12.KindAck {
+				fmt.Fprintf(p.W, "%s% X\n", strings.Repeat(" ", len(timeLayout)+8), frame)
+			}
+		}
+	}
+	stats.Tail = bufs[0].Len() + bufs[1].Len()
+	if stats.Tail > 0 {
+		fmt.Fprintf(p.W, "   %d byte(s) of incomplete frame at end of stream\n", stats.Tail)
+	}
+	return stats
+}
+
+const timeLayout = "2006-01-02 15:04:05.000000"
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sln/ft12"
+	"sln/ft12/capture"
+	"sln/ft12dump/internal/config"
+	"sln/ft12dump/internal/dump"
+)
+
+// Точка входа ft12dump. Читает .pcap (tcpdump) или запись -record клиента/эмулятора,
+// собирает TCP- и UDP-потоки к порту прибора (и обмен без IP из -pcap) и печатает разобранные кадры FT1.2
+func main() {
+	cfg := config.Load()
+	logger := log.New(os.Stderr, "ft12dump: ", 0)
+
+	if _, err := ft12.ParseDialect(cfg.Dialect); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	for _, def := range cfg.CRCDefs {
+		if err := ft12.RegisterCRCDef(def); err != nil {
+			logger.Fatalf("invalid config: %v", err)
+		}
+	}
+	if _, err := ft12.LookupChecksum(cfg.CRCMode); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	tc, err := ft12.LookupTimeCodec(cfg.TimeFormat)
+	if err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	if cfg.Port < 1 || cfg.Port > 0xFFFF {
+		logger.Fatalf("invalid config: port %d out of range 1..65535", cfg.Port)
+	}
+
+	p := &dump.Printer{
+		Codec: ft12.Codec{
+			Dialect:  ft12.Dialect(cfg.Dialect),
+			Checksum: ft12.ChecksumKind(cfg.CRCMode),
+			Strict:   cfg.StrictCRC,
+		},
+		TimeCodec: tc,
+		Hex:       cfg.Hex,
+		W:         os.Stdout,
+	}
+	var total dump.Stats
+	n := 0
+	for _, path := range cfg.Files {
+		streams, err := load(path, cfg.Port)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		if len(streams) == 0 {
+			logger.Printf("%s: no TCP/UDP streams on port %d and no non-IP exchange", path, cfg.Port)
+		}
+		for _, st := range streams {
+			n++
+			s := p.Stream(n, st)
+			total.Frames += s.Frames
+			total.BadCRC += s.BadCRC
+			total.Discarded += s.Discarded
+			total.Tail += s.Tail
+		}
+	}
+	fmt.Printf("== %d stream(s), %d frame(s), %d bad checksum, %d garbage byte(s), %d incomplete byte(s)\n",
+		n, total.Frames, total.BadCRC, total.Discarded, total.Tail)
+}
+
+// load читает потоки из .pcap или из JSONL-записи (определяется по первому байту файла)
+func load(path string, port int) ([]*capture.Stream, error) {
+	head := make([]byte, 1)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = f.Read(head)
+	_ = f.Close()
+	if bytes.Equal(head, []byte("{")) {
+		c, err := capture.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		return c.Streams(), nil
+	}
+	pf, err := capture.ReadPcap(path)
+	if err != nil {
+		return nil, err
+	}
+	return pf.Streams(port), nil
+}
@@ -1,18 +1,51 @@
 package config
 
-import "flag"
+import (
+	"flag"
+	"strings"
+)
 
 // содержит параметры запуска сервера
 type Config struct {
 	Host        string
 	Port        int
 	CRCMode     string
+	CRCDefs     stringList // дополнительные алгоритмы CRC для реестра ft12
+	Dialect     string
+	StrictCRC   bool
 	DelayMs     int
 	BadCRCProb  float64
 	FragProb    float64
 	AdapterAddr int
+	Broadcast   int // широковещательный адрес: выполнить без ответа (-1 - отключён)
 	LogFile     string
-	ReadTimeout int // секунды для таймаута чтения соединения
+	ReadTimeout int     // секунды для таймаута чтения соединения
+	MaxBuffer   int     // ограничение буфера приёма на соединение, байт
+	SegmentSize int     // байт полезных данных на кадр в многокадровых ответах (0 - максимум)
+	TimeFormat  string  // формат времени устройства (ft12.LookupTimeCodec)
+	RegMap      string  // JSON-файл карты регистров прибора (regmap)
+	LegacyEcho  bool    // отвечать на неизвестные команды эхом cmd + "OK" вместо ошибки
+	Scenario    string  // JSON-файл сценария ответов (emulator.Scenario)
+	Record      string  // файл записи обмена (capture JSONL)
+	Pcap        string  // файл записи обмена в формате libpcap
+	Replay      string  // отвечать клиентам записанными ответами прибора вместо эмуляции
+	ReplaySpeed float64 // темп воспроизведения: 1 - исходный, N - в N раз быстрее, 0 - без пауз
+	Transport   string  // tcp | udp | unix | pty
+	Upstream    string  // режим прокси: адрес прибора [вид:]адрес (tcp, udp, unix, serial); пусто - эмуляция
+	FaultDir    string  // к какому направлению прокси применять искажения: responses | requests | both
+	Learn       string  // режим прокси: файл профиля прибора, куда сохраняются его ответы
+	Profile     string  // отвечать по профилю прибора, снятому с -learn
+	Socket      string  // путь unix-сокета (-transport unix)
+	TLS         bool    // TLS поверх tcp/unix
+	TLSCert     string  // сертификат и ключ сервера (PEM); пусто - самоподписанный
+	TLSKey      string
+	TLSClientCA string // CA для проверки сертификатов клиентов; пусто - сертификат клиента не нужен
+	TLSExport   string // куда сохранить самоподписанный сертификат (для -tlsca клиента)
+	PTYLink     string // символическая ссылка на ведомую сторону псевдотерминала
+	Baud        int    // параметры линии псевдотерминала; Baud задаёт темп выдачи ответов
+	DataBits    int
+	Parity      string // N | E | O
+	StopBits    int
 }
 
 // парсит флаги командной строки и возвращает конфигурацию
@@ -21,14 +54,54 @@ func Load() *Config {
 
 	flag.StringVar(&confRes.Host, "host", "127.0.0.1", "listen host")
 	flag.IntVar(&confRes.Port, "port", 9000, "listen port")
-	flag.StringVar(&confRes.CRCMode, "crc", "sum", "crc mode: sum | crc16")
+	flag.StringVar(&confRes.CRCMode, "crc", "sum", "checksum algorithm: sum | crc16 | xor8 | crc8 | crc16-be | crc16-ccitt | crc16-x25 | crc16-kermit | name from -crcdef")
+	flag.StringVar(&confRes.Dialect, "dialect", "simplified", "frame dialect: simplified (68 L 68) | ft12 (68 L L 68)")
+	flag.BoolVar(&confRes.StrictCRC, "strict", false, "accept only frames signed with the configured -crc algorithm")
 	flag.IntVar(&confRes.DelayMs, "delay", 0, "fixed delay before responding (ms)")
 	flag.Float64Var(&confRes.BadCRCProb, "badcrc", 0.0, "probability [0..1] to send bad CRC in responses")
 	flag.Float64Var(&confRes.FragProb, "fragment", 0.0, "probability [0..1] to fragment responses")
 	flag.IntVar(&confRes.AdapterAddr, "adapter", 1, "adapter address byte (0..255)")
+	flag.IntVar(&confRes.Broadcast, "broadcast", 0xFF, "broadcast address: execute without reply (0..255, -1 = disabled)")
 	flag.StringVar(&confRes.LogFile, "log", "", "path to log file; empty = stdout")
 	flag.IntVar(&confRes.ReadTimeout, "readtimeout", 300, "connection read timeout in seconds")
 
+	flag.IntVar(&confRes.SegmentSize, "segsize", 0, "payload bytes per frame in multi-frame responses (0 = max that fits LEN)")
+	flag.IntVar(&confRes.MaxBuffer, "maxbuf", 4096, "max receive buffer per connection in bytes")
+	flag.StringVar(&confRes.TimeFormat, "timefmt", "ascii", "time format in read-time responses: ascii | cp56 (CP56Time2a) | bcd")
+	flag.StringVar(&confRes.RegMap, "regmap", "", "JSON register map of the emulated device (empty = no registers)")
+	flag.BoolVar(&confRes.LegacyEcho, "echo", false, "reply to unknown commands with legacy cmd+\"OK\" echo instead of an unknown-command error")
+	flag.StringVar(&confRes.Scenario, "scenario", "", "JSON scenario file: scripted responses and faults per request (empty = off)")
+	flag.StringVar(&confRes.Record, "record", "", "record all RX/TX chunks with timestamps to a JSONL capture file")
+	flag.StringVar(&confRes.Pcap, "pcap", "", "write all RX/TX chunks to a libpcap file with synthesized Ethernet/IP/TCP headers (for Wireshark)")
+	flag.StringVar(&confRes.Replay, "replay", "", "replay a capture file as the device: each accepted connection gets the next recorded one")
+	flag.Float64Var(&confRes.ReplaySpeed, "replayspeed", 1, "replay pace: 1 = original timing, N = N times faster, 0 = no pauses")
+	flag.StringVar(&confRes.Transport, "transport", "tcp", "how clients reach the emulator: tcp | udp (-host/-port) | unix (-socket) | pty (pseudo-terminal serial device, Linux only)")
+	flag.StringVar(&confRes.Upstream, "upstream", "", "proxy mode: forward every connection to this device ([tcp:]host:port, udp:host:port, unix:path, serial:device) and log decoded frames (empty = emulate)")
+	flag.StringVar(&confRes.FaultDir, "faultdir", "responses", "proxy mode: apply -delay/-badcrc/-fragment to responses | requests | both")
+	flag.StringVar(&confRes.Learn, "learn", "", "proxy mode: store device responses per request into this profile file (existing file is extended)")
+	flag.StringVar(&confRes.Profile, "profile", "", "answer from a device profile recorded with -learn (time responses use the current clock)")
+	flag.StringVar(&confRes.Socket, "socket", "", "unix socket path for -transport unix")
+	flag.BoolVar(&confRes.TLS, "tls", false, "accept TLS connections (tcp and unix transports)")
+	flag.StringVar(&confRes.TLSCert, "tlscert", "", "TLS certificate PEM file (empty with -tlskey = generate a self-signed one)")
+	flag.StringVar(&confRes.TLSKey, "tlskey", "", "TLS private key PEM file")
+	flag.StringVar(&confRes.TLSClientCA, "tlsclientca", "", "require client certificates signed by this CA PEM file (mutual TLS)")
+	flag.StringVar(&confRes.TLSExport, "tlsexport", "", "write the generated self-signed certificate to this PEM file")
+	flag.StringVar(&confRes.PTYLink, "ptylink", "", "create a symlink with this name to the pty slave device (e.g. /tmp/ttyTTR20)")
+	flag.IntVar(&confRes.Baud, "baud", 9600, "pty line baud rate; responses are paced at this rate")
+	flag.IntVar(&confRes.DataBits, "databits", 8, "pty data bits (5..8)")
+	flag.StringVar(&confRes.Parity, "parity", "E", "pty parity: N | E | O (FT1.2 uses 8E1)")
+	flag.IntVar(&confRes.StopBits, "stopbits", 1, "pty stop bits (1 | 2)")
+	flag.Var(&confRes.CRCDefs, "crcdef", "extra CRC definition name:width=16,poly=0x1021,init=0xFFFF,reflect=false,xorout=0,order=be (repeatable)")
 	flag.Parse()
 	return confRes
 }
+
+// stringList - флаг, который можно указать несколько раз
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, "; ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -1,21 +1,21 @@
 package emu
 
 import (
-	"bytes"
+	"errors"
 	"log"
-	"math/rand"
 	"net"
 	"runtime/debug"
+	"sln/ft12"
 	"sln/internal/config"
 	"sln/internal/emulator"
-	"sln/internal/frame"
 	"sln/internal/util"
 	"time"
 )
 
 // handleConnection обслуживает одно TCP-соединение
-// Защищён от паники, читает байты, собирает фреймы и отвечает
-func handleConnection(conn net.Conn, cfg *config.Config, logger *log.Logger) {
+// Защищён от паники, читает байты, собирает фреймы, передаёт команды обработчикам из реестра
+// и отправляет их ответы через общий конвейер
+func handleConnection(conn net.Conn, cfg *config.Config, logger *log.Logger, dev *emulator.Device, handlers *emulator.Handlers, links *emulator.Links, cursor *emulator.ScenarioCursor) {
 	// recover чтобы паника в обработчике не убивала весь сервер
 	defer func() {
 		if r := recover(); r != nil {
@@ -30,127 +30,175 @@ func handleConnection(conn net.Conn, cfg *config.Config, logger *log.Logger) {
 		logger.Printf("[%s] connection handler finished", conn.RemoteAddr())
 	}()
 
-	var buf bytes.Buffer
-	tmp := make([]byte, 4096)
+	codec := dev.Codec
+	dec := ft12.NewDecoder(conn, codec, cfg.MaxBuffer)
+	pipe := newConnPipeline(conn, cfg, logger)
 	readTimeout := time.Duration(cfg.ReadTimeout) * time.Second
+	defer func() {
+		logger.Printf("[%s] decoder stats: %s", conn.RemoteAddr(), dec.Stats())
+	}()
 
 	for {
-		// Устанавливаем deadline для чтения
+		// Устанавливаем deadline для чтения и ждём следующий полный фрейм;
+		// мусор и ложные старты декодер отбрасывает сам
 		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
-		n, err := conn.Read(tmp)
+		frameBytes, kind, err := dec.Next()
 		if err != nil {
 			// При ошибке чтения закрываем хендлер
 			logger.Printf("[%s] read error: %v", conn.RemoteAddr(), err)
 			return
 		}
-		if n == 0 {
+		logger.Printf("[%s] RX (%s): %s", conn.RemoteAddr(), kind, util.HexDump(frameBytes))
+
+		// Проверяем контрольную сумму/формат фрейма и разбираем control, addr, data
+		req, err := codec.Decode(frameBytes)
+		if err != nil {
+			logger.Printf("[%s] frame verification failed: %v", conn.RemoteAddr(), err)
+			// Игнорируем некорректный фрейм и ждём следующий
 			continue
 		}
-		// Пишем полученные байты в буфер для парсинга фреймов
-		buf.Write(tmp[:n])
-
-		// Пока есть полный фрейм - извлекаем и обрабатываем
-		for {
-			frameBytes, ok := frame.ExtractFrame(&buf)
-			if !ok {
-				break
-			}
-			logger.Printf("[%s] RX: %s", conn.RemoteAddr(), util.HexDump(frameBytes))
+		if req.Kind == ft12.KindVariable && req.ChecksumKind != codec.Checksum {
+			logger.Printf("[%s] client appears to use %s (configured %s)", conn.RemoteAddr(), req.ChecksumKind, codec.Checksum)
+		}
 
-			// Проверяем контрольную сумму/формат фрейма
-			if err := frame.VerifyFrame(frameBytes); err != nil {
-				logger.Printf("[%s] frame verification failed: %v", conn.RemoteAddr(), err)
-				// Игнорируем некорректный фрейм и ждём следующий
+		if req.Kind == ft12.KindAck {
+			// Подтверждение от ведущего - эмулятору на него отвечать нечего
+			logger.Printf("[%s] single-char ack received, ignoring", conn.RemoteAddr())
+			continue
+		}
+
+		// Как устройство на шине: чужие кадры игнорируем, широковещательные выполняем молча
+		match := emulator.MatchAddress(req.Address, dev.Addr, cfg.Broadcast)
+		if match == emulator.AddrOther {
+			logger.Printf("[%s] frame for address 0x%02X ignored (own 0x%02X)", conn.RemoteAddr(), req.Address, dev.Addr)
+			continue
+		}
+		ctrl := ft12.ParseControl(req.Control)
+		// Состояние канала (FCB и последний ответ) общее для всех соединений с этим адресом;
+		// широковещательный сброс канала относится к собственному адресу
+		link := links.Get(dev.Addr)
+
+		var frames [][]byte
+		if req.Kind == ft12.KindShort {
+			// Короткий кадр канального уровня: сброс канала, запрос состояния и т.п.
+			logger.Printf("[%s] link request (ctrl=[%s] addr=0x%02X)", conn.RemoteAddr(), ctrl, req.Address)
+			if ctrl.Func == ft12.FuncResetLink {
+				link.Reset()
+			}
+			if match == emulator.AddrBroadcast {
+				logger.Printf("[%s] broadcast link request executed, no reply", conn.RemoteAddr())
 				continue
 			}
-
-			// Базовый разбор: control, addr, data (если есть).
-			if len(frameBytes) < 6 {
-				logger.Printf("[%s] frame too short", conn.RemoteAddr())
+			frames = [][]byte{emulator.BuildLinkResponse(req.Control, req.Address)}
+		} else {
+			// Повтор тех же байт запроса с тем же FCB после сброса канала: ответ потерялся,
+			// команду не выполняем повторно.
+			// На широковещательные кадры ответа нет, поэтому и повторять нечего
+			if match == emulator.AddrOwn && link.Duplicate(ctrl, frameBytes) {
+				logger.Printf("[%s] duplicate request (ctrl=[%s] addr=0x%02X) - replaying cached response", conn.RemoteAddr(), ctrl, req.Address)
+				if err := sendAll(pipe, link.Last()); err != nil {
+					return
+				}
 				continue
 			}
-			control := frameBytes[3]
-			addr := frameBytes[4]
-			data := frame.PayloadData(frameBytes)
-			var cmd byte
-			if len(data) > 0 {
-				cmd = data[0]
+
+			cmdReq := &emulator.Request{
+				Peer:  conn.RemoteAddr().String(),
+				Frame: req,
+				Ctrl:  ctrl,
+				Cmd:   firstByte(req.Data),
+				Data:  req.Data,
 			}
 
-			// Обработка известных команд
-			switch cmd {
-			case 0x01:
-				// Команда чтения времени
-				logger.Printf("[%s] read-time request (ctrl=0x%02X addr=0x%02X)", conn.RemoteAddr(), control, addr)
-				resp := emulator.BuildTimeResponse(control, addr, data, cfg.CRCMode, byte(cfg.AdapterAddr))
+			// Шаг сценария (если запрос совпал с текущим) определяет ответ и искажения
+			step, idx := cursor.Next(cmdReq.Cmd, req.Address)
+			if step != nil {
+				logger.Printf("[%s] scenario step %d %s: action=%s fault=%s", conn.RemoteAddr(), idx, step.Name, step.Action, step.Fault)
+				cmdReq.TimeShift = step.TimeShift()
+			}
+			switch {
+			case step != nil && step.Action == emulator.ActionSilent:
+				// Ответа нет; повтор клиента с тем же FCB считается новым запросом
+				continue
+			case step != nil && step.Action == emulator.ActionError:
+				frames = execute(dev, rejectHandler(ft12.ErrorCode(step.ErrorCode)), cmdReq)
+			default:
+				frames = execute(dev, handlers.Lookup(cmdReq.Cmd), cmdReq)
+			}
+			if match == emulator.AddrBroadcast {
+				logger.Printf("[%s] broadcast cmd 0x%02X executed, no reply", conn.RemoteAddr(), cmdReq.Cmd)
+				continue
+			}
+			// Сохраняем ответ до тестовых искажений, чтобы повтор ушёл корректным
+			link.Remember(frames)
 
-				// Опциональня искусственная задержка для тестов
-				if cfg.DelayMs > 0 {
-					time.Sleep(time.Duration(cfg.DelayMs) * time.Millisecond)
+			if step != nil {
+				if err := sendAll(stepPipeline(pipe, step, cfg.CRCMode, logger, conn), frames); err != nil {
+					return
 				}
+				continue
+			}
+		}
 
-				// Иногда инжектим плохой CRC (для тестирования).
-				if rand.Float64() < cfg.BadCRCProb {
-					logger.Printf("[%s] injecting bad CRC", conn.RemoteAddr())
-					frame.CorruptChecksum(resp, cfg.CRCMode)
-				}
+		if err := sendAll(pipe, frames); err != nil {
+			return
+		}
+	}
+}
 
-				// Иногда фрагментируем ответ на две части
-				if rand.Float64() < cfg.FragProb && len(resp) > 1 {
-					i := len(resp) / 2
-					if i < 1 {
-						i = 1
-					}
-					logger.Printf("[%s] sending fragmented response (%d + %d)", conn.RemoteAddr(), i, len(resp)-i)
-					if _, err := conn.Write(resp[:i]); err != nil {
-						logger.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
-						return
-					}
-					time.Sleep(40 * time.Millisecond)
-					if _, err := conn.Write(resp[i:]); err != nil {
-						logger.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
-						return
-					}
-				} else {
-					// Отправляем полный ответ
-					if _, err := conn.Write(resp); err != nil {
-						logger.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
-						return
-					}
-				}
-				logger.Printf("[%s] TX: %s", conn.RemoteAddr(), util.HexDump(resp))
-			default:
-				// Для неизвестных команд отправляем ACK/echo
-				logger.Printf("[%s] generic/unknown cmd 0x%02X - sending ACK", conn.RemoteAddr(), cmd)
-				resp := emulator.BuildAckResponse(control, addr, data, cfg.CRCMode, byte(cfg.AdapterAddr))
-				if cfg.DelayMs > 0 {
-					time.Sleep(time.Duration(cfg.DelayMs) * time.Millisecond)
-				}
-				if rand.Float64() < cfg.BadCRCProb {
-					frame.CorruptChecksum(resp, cfg.CRCMode)
-				}
-				if rand.Float64() < cfg.FragProb && len(resp) > 1 {
-					i := len(resp) / 2
-					if i < 1 {
-						i = 1
-					}
-					if _, err := conn.Write(resp[:i]); err != nil {
-						logger.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
-						return
-					}
-					time.Sleep(40 * time.Millisecond)
-					if _, err := conn.Write(resp[i:]); err != nil {
-						logger.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
-						return
-					}
-				} else {
-					if _, err := conn.Write(resp); err != nil {
-						logger.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
-						return
-					}
-				}
-				logger.Printf("[%s] TX: %s", conn.RemoteAddr(), util.HexDump(resp))
-			}
+// execute вызывает обработчик команды; ошибка обработчика превращается в отрицательный ответ
+func execute(dev *emulator.Device, h emulator.Handler, req *emulator.Request) [][]byte {
+	frames, err := h.Handle(dev, req)
+	if err == nil {
+		return frames
+	}
+	if !errors.Is(err, ft12.ErrUnknownCommand) {
+		dev.Logger.Printf("[%s] cmd 0x%02X rejected: %v", req.Peer, req.Cmd, err)
+	}
+	resp, berr := emulator.BuildErrorResponse(dev.Codec, req.Cmd, emulator.ErrorCode(err), dev.CRCMode, dev.Addr)
+	if berr != nil {
+		dev.Logger.Printf("[%s] cannot build response: %v", req.Peer, berr)
+		return nil
+	}
+	return [][]byte{resp}
+}
+
+// rejectHandler отвечает на любую команду отрицательным ответом code (шаг сценария action=error)
+func rejectHandler(code ft12.ErrorCode) emulator.Handler {
+	return emulator.HandlerFunc(func(*emulator.Device, *emulator.Request) ([][]byte, error) {
+		return nil, &ft12.DeviceError{Code: code}
+	})
+}
+
+// stepPipeline добавляет перед конвейером соединения искажения шага сценария:
+// задержку, гарантированно битую контрольную сумму или фрагментацию
+func stepPipeline(pipe *Pipeline, step *emulator.Step, crcMode string, logger *log.Logger, conn net.Conn) *Pipeline {
+	logf := func(format string, args ...interface{}) {
+		logger.Printf("[%s] scenario: "+format, append([]interface{}{conn.RemoteAddr()}, args...)...)
+	}
+	stages := []Stage{DelayStage(time.Duration(step.DelayMs) * time.Millisecond)}
+	switch step.Fault {
+	case emulator.FaultBadCRC:
+		stages = append(stages, CorruptStage(1, crcMode, logf))
+	case emulator.FaultFragment:
+		stages = append(stages, FragmentStage(1, 40*time.Millisecond, logf))
+	}
+	return NewPipeline(pipe.Send, stages...)
+}
+
+// sendAll отправляет кадры ответа через конвейер по очереди
+func sendAll(pipe *Pipeline, frames [][]byte) error {
+	for _, f := range frames {
+		if err := pipe.Send(f); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+func firstByte(b []byte) byte {
+	if len(b) == 0 {
+		return 0
+	}
+	return b[0]
 }
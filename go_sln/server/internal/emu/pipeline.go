@@ -0,0 +1,108 @@
+package emu
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"sln/ft12"
+	"sln/internal/config"
+	"sln/internal/util"
+	"time"
+)
+
+// Stage - этап конвейера ответа. Получает кадр и передаёт его дальше через next:
+// изменённым, с задержкой или по частям
+type Stage func(frame []byte, next func([]byte) error) error
+
+// Pipeline - общий конвейер отправки ответов. Любые кадры, которые вернул обработчик
+// команды или канальный уровень, проходят одни и те же этапы и затем пишутся в соединение
+type Pipeline struct {
+	stages []Stage
+	write  func([]byte) error
+}
+
+// NewPipeline собирает конвейер; этапы выполняются в порядке перечисления
+func NewPipeline(write func([]byte) error, stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages, write: write}
+}
+
+// Send отправляет кадр через все этапы
+func (p *Pipeline) Send(frame []byte) error { return p.run(0, frame) }
+
+func (p *Pipeline) run(i int, frame []byte) error {
+	if i == len(p.stages) {
+		return p.write(frame)
+	}
+	return p.stages[i](frame, func(b []byte) error { return p.run(i+1, b) })
+}
+
+// DelayStage - фиксированная задержка перед отправкой
+func DelayStage(d time.Duration) Stage {
+	return func(frame []byte, next func([]byte) error) error {
+		if d > 0 {
+			time.Sleep(d)
+		}
+		return next(frame)
+	}
+}
+
+// CorruptStage с вероятностью prob портит контрольную сумму кадра (в копии).
+// Короткие кадры всегда подписаны суммой, у 0xE5 контрольной суммы нет
+func CorruptStage(prob float64, crcMode string, logf func(string, ...interface{})) Stage {
+	return func(frame []byte, next func([]byte) error) error {
+		if rand.Float64() >= prob || len(frame) < 2 {
+			return next(frame)
+		}
+		logf("injecting bad CRC")
+		bad := append([]byte(nil), frame...)
+		mode := crcMode
+		if bad[0] == 0x10 {
+			mode = string(ft12.ChecksumSum)
+		}
+		ft12.CorruptChecksum(bad, mode)
+		return next(bad)
+	}
+}
+
+// FragmentStage с вероятностью prob отправляет кадр двумя частями с паузой между ними
+func FragmentStage(prob float64, pause time.Duration, logf func(string, ...interface{})) Stage {
+	return func(frame []byte, next func([]byte) error) error {
+		if rand.Float64() >= prob || len(frame) < 2 {
+			return next(frame)
+		}
+		i := len(frame) / 2
+		logf("sending fragmented response (%d + %d)", i, len(frame)-i)
+		if err := next(frame[:i]); err != nil {
+			return err
+		}
+		time.Sleep(pause)
+		return next(frame[i:])
+	}
+}
+
+// newConnPipeline - конвейер соединения с тестовыми искажениями из конфигурации:
+// задержка, битая контрольная сумма, фрагментация на две части
+func newConnPipeline(conn net.Conn, cfg *config.Config, logger *log.Logger) *Pipeline {
+	logf := func(format string, args ...interface{}) {
+		logger.Printf("[%s] "+format, append([]interface{}{conn.RemoteAddr()}, args...)...)
+	}
+	write := func(b []byte) error {
+		if _, err := conn.Write(b); err != nil {
+			logf("write error: %v", err)
+			return err
+		}
+		logf("TX: %s", util.HexDump(b))
+		return nil
+	}
+	return NewPipeline(write, faultStages(cfg, cfg.CRCMode, logf)...)
+}
+
+// faultStages - тестовые искажения из конфигурации (-delay, -badcrc, -fragment);
+// crcMode - алгоритм, которым подписаны искажаемые кадры
+func faultStages(cfg *config.Config, crcMode string, logf func(string, ...interface{})) []Stage {
+	return []Stage{
+		DelayStage(time.Duration(cfg.DelayMs) * time.Millisecond),
+		CorruptStage(cfg.BadCRCProb, crcMode, logf),
+		FragmentStage(cfg.FragProb, 40*time.Millisecond, logf),
+	}
+}
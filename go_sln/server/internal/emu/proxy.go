@@ -0,0 +1,303 @@
+package emu
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sln/ft12"
+	"sln/ft12/transport"
+	"sln/internal/config"
+	"sln/internal/util"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Направления искажений в режиме прокси (-faultdir)
+const (
+	FaultResponses = "responses" // только ответы прибора опрашивающей стороне (по умолчанию)
+	FaultRequests  = "requests"  // только запросы, пересылаемые прибору
+	FaultBoth      = "both"
+)
+
+// upstreamDialTimeout - сколько ждать подключения к прибору
+const upstreamDialTimeout = 5 * time.Second
+
+// partialHold - сколько направление с искажениями ждёт окончания незавершённого кадра,
+// прежде чем переслать накопленные байты как есть
+const partialHold = 500 * time.Millisecond
+
+// UpstreamTransport разбирает адрес прибора -upstream ([tcp:]host:port, udp:host:port,
+// unix:/path, serial:/dev/ttyUSB0). Для последовательного порта параметры линии берутся из
+// -baud, -databits, -parity, -stopbits
+func UpstreamTransport(cfg *config.Config) (transport.Transport, error) {
+	mode, err := SerialMode(cfg)
+	if err != nil && strings.HasPrefix(cfg.Upstream, transport.KindSerial+":") {
+		return nil, err
+	}
+	return transport.Parse(cfg.Upstream, mode)
+}
+
+// serveProxy - режим прокси (-upstream): соединение опрашивающей стороны пересылается прибору
+// или преобразователю по адресу -upstream, для каждого подключения - своё соединение с прибором.
+// Байты пересылаются как есть, вместе с мусором и кадрами с неверной суммой; копия потока
+// разбирается общим кодеком и пишется в лог с пояснениями, как в ft12dump.
+// Искажения -delay, -badcrc, -fragment применяются к каждому кадру направления -faultdir.
+// С -learn ответы прибора (до искажений) сохраняются в профиль
+func (s *Server) serveProxy(down net.Conn) {
+	peer := down.RemoteAddr().String()
+	defer func() {
+		_ = down.Close()
+		s.logger.Printf("[%s] connection handler finished", peer)
+	}()
+
+	up, err := s.up.Dial(upstreamDialTimeout)
+	if err != nil {
+		s.logger.Printf("[%s] upstream %s: %v", peer, s.up, err)
+		return
+	}
+	defer func() { _ = up.Close() }()
+	s.logger.Printf("[%s] proxying to %s (%s -> %s)", peer, s.up, up.LocalAddr(), up.RemoteAddr())
+
+	annot := ft12.Annotator{Codec: s.dev.Codec, TimeCodec: s.dev.TimeCodec}
+	faults := s.cfg.FaultDir
+	var learn *learner
+	if s.learn != nil {
+		learn = &learner{s: s, peer: peer, codec: s.dev.Codec}
+		learn.codec.Strict = false
+		defer learn.flush()
+	}
+	dirs := []*proxyDir{
+		// Таймаут простоя - только со стороны опрашивающей стороны, как у эмулятора
+		{s: s, peer: peer, annot: annot, name: "P->D", src: down, dst: up, learn: learn,
+			faults: faults == FaultRequests || faults == FaultBoth,
+			idle:   time.Duration(s.cfg.ReadTimeout) * time.Second},
+		{s: s, peer: peer, annot: annot, name: "D->P", src: up, dst: down, learn: learn, fromDevice: true,
+			faults: faults == FaultResponses || faults == FaultBoth},
+	}
+
+	// Когда одна сторона закрыла соединение, закрываем обе, чтобы остановить второе направление
+	done := make(chan struct{}, 2)
+	for _, d := range dirs {
+		go func(d *proxyDir) {
+			d.pump()
+			done <- struct{}{}
+		}(d)
+	}
+	<-done
+	_ = down.Close()
+	_ = up.Close()
+	<-done
+}
+
+// proxyDir - одно направление прокси: чтение из src и пересылка в dst
+type proxyDir struct {
+	s          *Server
+	peer       string
+	annot      ft12.Annotator
+	name       string // P->D | D->P
+	src, dst   net.Conn
+	fromDevice bool
+	faults     bool
+	idle       time.Duration // таймаут чтения из src; 0 - без таймаута
+	learn      *learner      // nil - без обучения
+
+	write   func([]byte) error
+	detect  ft12.Codec // нестрогий кодек: каким алгоритмом подписан кадр
+	pending []byte     // с искажениями: байты из буфера декодера, ещё не пересланные в dst
+}
+
+func (d *proxyDir) logf(format string, args ...interface{}) {
+	d.s.logger.Printf("[%s] %s "+format, append([]interface{}{d.peer, d.name}, args...)...)
+}
+
+// pump пересылает прочитанное из src в dst. Без искажений куски уходят сразу и без изменений,
+// а кадры выделяются из копии потока только для лога и обучения: мусор, обрывки и кадры
+// чужого формата доходят до другой стороны так же, как без прокси.
+// С искажениями пересылка идёт по кадрам, выделенным декодером: каждый кадр проходит
+// -delay, -badcrc, -fragment, мусор пересылается как есть, а начало кадра, который не
+// завершился за partialHold, - тоже как есть и без искажений
+func (d *proxyDir) pump() {
+	d.write = func(b []byte) error {
+		if _, err := d.dst.Write(b); err != nil {
+			d.logf("write error: %v", err)
+			return err
+		}
+		return nil
+	}
+	d.detect = d.annot.Codec
+	d.detect.Strict = false
+
+	dec := ft12.NewDecoder(nil, d.annot.Codec, d.s.cfg.MaxBuffer)
+	defer func() { d.logf("decoder stats: %s", dec.Stats()) }()
+	buf := make([]byte, 4096)
+	for {
+		hold := d.setDeadline()
+		n, err := d.src.Read(buf)
+		if n > 0 {
+			chunk := append([]byte(nil), buf[:n]...)
+			_, _ = dec.Write(chunk)
+			if d.faults {
+				d.pending = append(d.pending, chunk...)
+			}
+			if d.frames(dec) != nil {
+				return
+			}
+			if !d.faults && d.write(chunk) != nil {
+				return
+			}
+		}
+		if err != nil {
+			if hold && errors.Is(err, os.ErrDeadlineExceeded) {
+				d.logf("%d byte(s) of an incomplete frame (forwarded as is)", len(d.pending))
+				dec.Reset()
+				if d.forwardRaw(len(d.pending)) != nil {
+					return
+				}
+				continue
+			}
+			d.logf("read error: %v", err)
+			_ = d.forwardRaw(len(d.pending))
+			return
+		}
+	}
+}
+
+// setDeadline ставит таймаут чтения: простой src или, если в буфере ждёт начало кадра,
+// partialHold. Возвращает true, если сработать должен partialHold
+func (d *proxyDir) setDeadline() bool {
+	if d.idle <= 0 && !d.faults {
+		return false
+	}
+	var deadline time.Time
+	if d.idle > 0 {
+		deadline = time.Now().Add(d.idle)
+	}
+	hold := false
+	if len(d.pending) > 0 {
+		if at := time.Now().Add(partialHold); deadline.IsZero() || at.Before(deadline) {
+			deadline, hold = at, true
+		}
+	}
+	_ = d.src.SetReadDeadline(deadline)
+	return hold
+}
+
+// frames пишет в лог кадры, собранные декодером из копии потока, и передаёт их обучению.
+// С искажениями здесь же идёт пересылка: байты, отброшенные декодером, - как есть,
+// кадры - через конвейер искажений
+func (d *proxyDir) frames(dec *ft12.Decoder) error {
+	discarded := dec.Stats().Discarded
+	for {
+		frame, kind, ok := dec.Frame()
+		if n := dec.Stats().Discarded; n > discarded {
+			d.logf("%d garbage byte(s) (forwarded as is)", n-discarded)
+			if err := d.forwardRaw(int(n - discarded)); err != nil {
+				return err
+			}
+			discarded = n
+		}
+		if !ok {
+			return nil
+		}
+		line, bad := d.annot.Describe(frame, kind, d.fromDevice)
+		d.logf("%s: %s", line, util.HexDump(frame))
+		if d.learn != nil {
+			if d.fromDevice {
+				d.learn.response(frame, bad)
+			} else {
+				d.learn.request(frame, kind)
+			}
+		}
+		if d.faults {
+			d.pending = d.pending[len(frame):]
+			if err := d.framePipeline(frame).Send(frame); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// forwardRaw пересылает без искажений первые n ожидающих байт (только с искажениями:
+// без них поток уже переслан целиком)
+func (d *proxyDir) forwardRaw(n int) error {
+	if !d.faults || n == 0 {
+		return nil
+	}
+	b := d.pending[:n]
+	d.pending = d.pending[n:]
+	return d.write(b)
+}
+
+// framePipeline - конвейер искажений для одного кадра. Контрольная сумма портится тем
+// алгоритмом, которым кадр подписан на самом деле; если его не определить - алгоритмом -crc
+func (d *proxyDir) framePipeline(frame []byte) *Pipeline {
+	crcMode := d.s.cfg.CRCMode
+	if kind, err := d.detect.VerifyChecksum(frame); err == nil && kind != "" {
+		crcMode = string(kind)
+	}
+	return NewPipeline(d.write, faultStages(d.s.cfg, crcMode, d.logf)...)
+}
+
+// learner собирает пары «запрос - ответ» одного соединения прокси для профиля (-learn).
+// Ответом на запрос считаются все кадры прибора до следующего запроса опрашивающей стороны
+type learner struct {
+	s     *Server
+	peer  string
+	codec ft12.Codec
+
+	mu   sync.Mutex
+	addr byte
+	req  []byte // DATA текущего запроса; nil - ждать нечего
+	resp [][]byte
+	bad  bool // в ответе был кадр с неверной контрольной суммой
+}
+
+// request начинает новый запрос. Короткие кадры канального уровня в профиль не попадают,
+// но завершают предыдущий запрос; подтверждение 0xE5 от опрашивающей стороны пропускается
+func (l *learner) request(frame []byte, kind ft12.Kind) {
+	if kind == ft12.KindAck {
+		return
+	}
+	l.flush()
+	f, err := l.codec.Decode(frame)
+	if err != nil || kind != ft12.KindVariable || len(f.Data) == 0 {
+		return
+	}
+	l.mu.Lock()
+	l.addr, l.req = f.Address, f.Data
+	l.mu.Unlock()
+}
+
+func (l *learner) response(frame []byte, bad bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.req == nil {
+		return
+	}
+	l.resp = append(l.resp, frame)
+	l.bad = l.bad || bad
+}
+
+// flush сохраняет собранную пару в профиль; новый запрос сразу записывается в файл
+func (l *learner) flush() {
+	l.mu.Lock()
+	addr, req, resp, bad := l.addr, l.req, l.resp, l.bad
+	l.req, l.resp, l.bad = nil, nil, false
+	l.mu.Unlock()
+	switch {
+	case req == nil:
+		return
+	case len(resp) == 0:
+		l.s.logger.Printf("[%s] learn: no response to cmd 0x%02X - not learned", l.peer, req[0])
+		return
+	case bad:
+		l.s.logger.Printf("[%s] learn: bad checksum in response to cmd 0x%02X - not learned", l.peer, req[0])
+		return
+	}
+	if l.s.learn.Learn(addr, req, resp) {
+		l.s.logger.Printf("[%s] learn: new request %s addr 0x%02X % X -> %d frame(s), %d request(s) in profile",
+			l.peer, ft12.CommandName(req[0]), addr, req, len(resp), l.s.learn.Len())
+		l.s.saveProfile()
+	}
+}
@@ -0,0 +1,93 @@
+package emu
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sln/ft12/serial"
+	"sln/internal/config"
+)
+
+// SerialMode собирает параметры линии псевдотерминала из конфигурации и проверяет их
+func SerialMode(cfg *config.Config) (serial.Mode, error) {
+	parity, err := serial.ParseParity(cfg.Parity)
+	if err != nil {
+		return serial.Mode{}, err
+	}
+	m := serial.Mode{Baud: cfg.Baud, DataBits: cfg.DataBits, Parity: parity, StopBits: cfg.StopBits}
+	return m, m.Validate()
+}
+
+// servePTY - режим псевдотерминала (-transport pty): эмулятор обслуживает ведущую сторону,
+// внешняя программа открывает ведомую (/dev/pts/N или ссылку -ptylink) как последовательный порт.
+// Ответы выдаются с темпом -baud. Соединения как такового нет, поэтому обмен идёт сессиями:
+// после таймаута чтения состояние канала адреса эмулятора (FCB и сохранённый ответ)
+// сбрасывается и обслуживание продолжается
+func (s *Server) servePTY() error {
+	// Параметры линии проверены при старте (main)
+	mode, _ := SerialMode(s.cfg)
+	pty, err := serial.OpenPTY(mode)
+	if err != nil {
+		return err
+	}
+	s.pty = pty
+	s.logger.Printf("serial device %s (%s)", pty.Name, mode)
+	if s.cfg.PTYLink != "" {
+		if err := symlinkPTY(pty.Name, s.cfg.PTYLink); err != nil {
+			_ = pty.Close()
+			return err
+		}
+		defer func() { _ = os.Remove(s.cfg.PTYLink) }()
+		s.logger.Printf("symlink %s -> %s", s.cfg.PTYLink, pty.Name)
+	}
+
+	// Записываем кадры целиком, до разбиения на символы
+	conn := serial.Paced(pty, mode)
+	if s.rec != nil {
+		conn = s.rec.Wrap(conn)
+	}
+	if s.pcap != nil {
+		conn = s.pcap.Wrap(conn)
+	}
+	defer func() { _ = conn.Close() }()
+
+	for {
+		if s.replay != nil && s.replay.done() {
+			s.logger.Printf("[%s] all recorded connections replayed - closing", pty.Name)
+			return nil
+		}
+		s.handle(ptySession{conn})
+		select {
+		case <-s.close:
+			return nil
+		default:
+			s.links.Get(s.dev.Addr).Drop()
+			s.logger.Printf("[%s] session ended, link state reset", pty.Name)
+		}
+	}
+}
+
+// ptySession - сессия обмена на псевдотерминале: обработчик закрывает её по выходу,
+// но сам терминал остаётся открытым до Stop
+type ptySession struct {
+	net.Conn
+}
+
+func (ptySession) Close() error { return nil }
+
+// symlinkPTY создаёт ссылку link на устройство; существующая ссылка (например, от прошлого
+// запуска) заменяется, обычный файл - нет
+func symlinkPTY(dev, link string) error {
+	if fi, err := os.Lstat(link); err == nil {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("ptylink %s exists and is not a symlink", link)
+		}
+		if err := os.Remove(link); err != nil {
+			return fmt.Errorf("ptylink: %w", err)
+		}
+	}
+	if err := os.Symlink(dev, link); err != nil {
+		return fmt.Errorf("ptylink: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package emu
+
+import (
+	"net"
+	"sln/ft12/capture"
+	"sync"
+	"time"
+)
+
+// replaySource раздаёт записанные соединения принятым клиентам по порядку
+type replaySource struct {
+	capt  *capture.Capture
+	conns [][]capture.Record
+
+	mu   sync.Mutex
+	next int
+}
+
+func loadReplay(path string) (*replaySource, error) {
+	capt, err := capture.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &replaySource{capt: capt, conns: capt.Connections()}, nil
+}
+
+// take возвращает следующее записанное соединение и его номер (с 1); false - записи кончились
+func (r *replaySource) take() ([]capture.Record, int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next == len(r.conns) {
+		return nil, 0, false
+	}
+	r.next++
+	return r.conns[r.next-1], r.next, true
+}
+
+// done - все записанные соединения уже розданы
+func (r *replaySource) done() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.next == len(r.conns)
+}
+
+// serveReplay играет роль прибора: отправляет клиенту записанные куски прибора,
+// дожидаясь перед каждым записанных кусков опрашивающей стороны. Команды не выполняются
+func (s *Server) serveReplay(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		s.logger.Printf("[%s] connection handler finished", conn.RemoteAddr())
+	}()
+
+	recs, n, ok := s.replay.take()
+	if !ok {
+		s.logger.Printf("[%s] no recorded connections left to replay - closing", conn.RemoteAddr())
+		return
+	}
+	s.logger.Printf("[%s] replaying recorded connection %d/%d (%s)", conn.RemoteAddr(), n, len(s.replay.conns), recs[0].Conn)
+
+	peer := conn.RemoteAddr().String()
+	r := &capture.Replayer{
+		Speed: s.cfg.ReplaySpeed,
+		Wait:  time.Duration(s.cfg.ReadTimeout) * time.Second,
+		Codec: s.dev.Codec,
+		Logf: func(format string, args ...interface{}) {
+			s.logger.Printf("["+peer+"] "+format, args...)
+		},
+	}
+	st, err := r.Play(conn, recs, s.replay.capt.FromDevice)
+	if err != nil {
+		s.logger.Printf("[%s] replay stopped: %v", peer, err)
+	}
+	s.logger.Printf("[%s] replay done: sent=%d matched=%d differed=%d missing=%d", peer, st.Sent, st.Matched, st.Differed, st.Missing)
+}
@@ -1,45 +1,246 @@
 package emu
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"sln/ft12"
+	"sln/ft12/capture"
+	"sln/ft12/regmap"
+	"sln/ft12/serial"
+	"sln/ft12/transport"
 	"sln/internal/config"
+	"sln/internal/emulator"
+	"strconv"
 	"sync"
 )
 
-// Server представляет TCP-эмулятор
+// Виды транспорта эмулятора (-transport)
+const (
+	TransportTCP  = "tcp"
+	TransportUDP  = "udp"
+	TransportUnix = "unix"
+	TransportPTY  = "pty"
+)
+
+// Server представляет эмулятор: сервер TCP, UDP, unix-сокета или псевдотерминал (-transport)
 type Server struct {
 	cfg    *config.Config
 	logger *log.Logger
 	ln     net.Listener
+	pc     net.PacketConn      // UDP (-transport udp)
+	tls    *tls.Config         // TLS поверх tcp/unix (-tls), nil - без шифрования
+	learn  *emulator.Profile   // профиль, который снимается в режиме прокси (-learn), nil - не снимается
+	up     transport.Transport // канал к прибору в режиме прокси (-upstream), nil - эмуляция
 	wg     sync.WaitGroup
 	close  chan struct{}
 	closed bool
 	mu     sync.Mutex
+	dev    *emulator.Device   // эмулируемый прибор, общий для всех соединений
+	cmds   *emulator.Handlers // обработчики команд по коду
+	links  *emulator.Links    // состояние канала по адресам, переживает переподключения
+	script *emulator.Scenario // сценарий ответов (-scenario), nil - без сценария
+	cursor *emulator.ScenarioCursor
+	rec    *capture.Recorder   // запись обмена (-record), nil - не пишем
+	pcap   *capture.PcapWriter // запись обмена в .pcap (-pcap), nil - не пишем
+	replay *replaySource       // воспроизведение записи (-replay), nil - обычная эмуляция
+	pty    *serial.PTY         // псевдотерминал (-transport pty), nil - TCP
 }
 
-// NewServer создаёт новый экземпляр сервера с конфигом и логгером
-func NewServer(cfg *config.Config, logger *log.Logger) *Server {
-	return &Server{
+// NewServer создаёт новый экземпляр сервера с конфигом и логгером.
+// Загружает карту регистров (-regmap), сценарий (-scenario), запись (-replay) и
+// сертификаты TLS (-tls), открывает файлы записи обмена (-record, -pcap), если они заданы
+func NewServer(cfg *config.Config, logger *log.Logger) (*Server, error) {
+	var regs *regmap.Map
+	if cfg.RegMap != "" {
+		m, err := regmap.Load(cfg.RegMap)
+		if err != nil {
+			return nil, fmt.Errorf("regmap: %w", err)
+		}
+		regs = m
+		logger.Printf("register map %s: %d register(s)", cfg.RegMap, len(m.Registers))
+	}
+	s := &Server{
 		cfg:    cfg,
 		logger: logger,
 		close:  make(chan struct{}),
+		dev:    newDevice(cfg, logger, regs),
+		cmds:   emulator.DefaultHandlers(cfg.LegacyEcho),
+		links:  emulator.NewLinks(),
+	}
+	if cfg.Scenario != "" {
+		sc, err := emulator.LoadScenario(cfg.Scenario)
+		if err != nil {
+			return nil, fmt.Errorf("scenario: %w", err)
+		}
+		s.script = sc
+		if sc.Cursor == emulator.CursorGlobal {
+			s.cursor = sc.NewCursor()
+		}
+		logger.Printf("scenario %s: %d step(s), cursor=%s loop=%v", cfg.Scenario, len(sc.Steps), sc.Cursor, sc.Loop)
+	}
+	if cfg.Replay != "" {
+		src, err := loadReplay(cfg.Replay)
+		if err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
+		}
+		s.replay = src
+		logger.Printf("replaying %s (recorded by %s): %d connection(s), speed=%g", cfg.Replay, src.capt.Side, len(src.conns), cfg.ReplaySpeed)
+	}
+	if cfg.Upstream != "" {
+		up, err := UpstreamTransport(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("upstream: %w", err)
+		}
+		s.up = up
+		logger.Printf("proxy mode: forwarding to %s, faults applied to %s", up, cfg.FaultDir)
+	}
+	if cfg.Learn != "" {
+		pr, err := learnProfile(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("learn: %w", err)
+		}
+		s.learn = pr
+		logger.Printf("learning device profile into %s (%d request(s) known)", cfg.Learn, pr.Len())
+	}
+	if cfg.Profile != "" {
+		pr, err := emulator.LoadProfile(cfg.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("profile: %w", err)
+		}
+		if err := checkProfile(pr, cfg); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", cfg.Profile, err)
+		}
+		// Все команды сначала ищутся в профиле; чего там нет, выполняют встроенные обработчики
+		cmds := emulator.NewHandlers()
+		cmds.Fallback = pr.Handler(s.cmds)
+		s.cmds = cmds
+		logger.Printf("device profile %s: %d request(s) learned from %s", cfg.Profile, pr.Len(), pr.Device)
+	}
+	if cfg.TLS {
+		tc, err := newTLSConfig(cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		s.tls = tc
+	}
+	if cfg.Record != "" {
+		rec, err := capture.Create(cfg.Record, capture.SideServer)
+		if err != nil {
+			return nil, fmt.Errorf("record: %w", err)
+		}
+		s.rec = rec
+		logger.Printf("recording traffic to %s", cfg.Record)
+	}
+	if cfg.Pcap != "" {
+		pw, err := capture.CreatePcap(cfg.Pcap, capture.SideServer)
+		if err != nil {
+			return nil, fmt.Errorf("pcap: %w", err)
+		}
+		s.pcap = pw
+		logger.Printf("writing pcap to %s", cfg.Pcap)
+	}
+	return s, nil
+}
+
+// learnProfile открывает профиль для обучения: существующий файл дополняется, иначе создаётся новый
+func learnProfile(cfg *config.Config) (*emulator.Profile, error) {
+	if _, err := os.Stat(cfg.Learn); os.IsNotExist(err) {
+		return emulator.NewProfile(cfg.Upstream, cfg.Dialect, cfg.TimeFormat), nil
+	}
+	pr, err := emulator.LoadProfile(cfg.Learn)
+	if err != nil {
+		return nil, err
+	}
+	return pr, checkProfile(pr, cfg)
+}
+
+// checkProfile проверяет, что профиль снят с теми же -dialect и -timefmt: иначе кадры
+// не разобрать, а время в ответах подставится в чужом формате
+func checkProfile(pr *emulator.Profile, cfg *config.Config) error {
+	if pr.Dialect != cfg.Dialect {
+		return fmt.Errorf("learned with -dialect %s, not %s", pr.Dialect, cfg.Dialect)
+	}
+	if pr.TimeFormat != cfg.TimeFormat {
+		return fmt.Errorf("learned with -timefmt %s, not %s", pr.TimeFormat, cfg.TimeFormat)
 	}
+	return nil
 }
 
-// Start запускает TCP-слушатель и принимает входящие подключения
+// saveProfile записывает снимаемый профиль (-learn)
+func (s *Server) saveProfile() {
+	if err := s.learn.Save(s.cfg.Learn); err != nil {
+		s.logger.Printf("learn: save %s: %v", s.cfg.Learn, err)
+	}
+}
+
+// connCursor возвращает курсор сценария для нового соединения: общий или собственный
+func (s *Server) connCursor() *emulator.ScenarioCursor {
+	if s.script == nil || s.cursor != nil {
+		return s.cursor
+	}
+	return s.script.NewCursor()
+}
+
+// newDevice собирает эмулируемый прибор из конфигурации
+func newDevice(cfg *config.Config, logger *log.Logger, regs *regmap.Map) *emulator.Device {
+	// Формат времени проверен при старте (main)
+	timeCodec, _ := ft12.LookupTimeCodec(cfg.TimeFormat)
+	return &emulator.Device{
+		Codec: ft12.Codec{
+			Dialect:  ft12.Dialect(cfg.Dialect),
+			Checksum: ft12.ChecksumKind(cfg.CRCMode),
+			Strict:   cfg.StrictCRC,
+		},
+		CRCMode:     cfg.CRCMode,
+		Addr:        byte(cfg.AdapterAddr),
+		TimeCodec:   timeCodec,
+		Clock:       &emulator.Clock{},
+		Registers:   emulator.NewRegisters(regs),
+		SegmentSize: cfg.SegmentSize,
+		Logger:      logger,
+	}
+}
+
+// Handle регистрирует обработчик дополнительной команды прибора.
+// Вызывается до Start; встроенные команды переопределить нельзя
+func (s *Server) Handle(cmd byte, h emulator.Handler) error {
+	return s.cmds.Register(cmd, h)
+}
+
+// Start запускает слушатель (-transport tcp | unix) и принимает входящие подключения,
+// либо обслуживает UDP (serveUDP) или псевдотерминал (servePTY)
 // Функция блокирует до Stop() или ошибки
 func (s *Server) Start() error {
-	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
-	ln, err := net.Listen("tcp", addr)
+	switch s.cfg.Transport {
+	case TransportPTY:
+		s.wg.Add(1)
+		defer s.wg.Done()
+		return s.servePTY()
+	case TransportUDP:
+		return s.serveUDP()
+	}
+	network, addr := "tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	if s.cfg.Transport == TransportUnix {
+		network, addr = "unix", s.cfg.Socket
+		if err := removeStaleSocket(addr); err != nil {
+			return err
+		}
+	}
+	ln, err := net.Listen(network, addr)
 	if err != nil {
 		return err
 	}
+	if s.tls != nil {
+		ln = tls.NewListener(ln, s.tls)
+		network += "+tls"
+	}
 	s.ln = ln
-	s.logger.Printf("listening on %s", addr)
+	s.logger.Printf("listening on %s %s", network, addr)
 
-	for {
+	for n := 1; ; n++ {
 		conn, err := ln.Accept()
 		if err != nil {
 			// При закрытии сервера Accept вернёт ошибку; тогда корректно выходим
@@ -51,16 +252,76 @@ func (s *Server) Start() error {
 				continue
 			}
 		}
-		// Новое подключение - обрабатываем в отдельной горутине
-		s.logger.Printf("accepted connection from %s", conn.RemoteAddr())
+		tc, _ := conn.(*tls.Conn)
+		if s.cfg.Transport == TransportUnix {
+			// У клиентов unix-сокета адреса нет (пусто или "@"), а по нему различаются соединения
+			// в логах и в записи обмена
+			conn = namedConn{Conn: conn, remote: &net.UnixAddr{Name: fmt.Sprintf("%s#%d", addr, n), Net: "unix"}}
+		}
+		if tc == nil {
+			s.serve(conn)
+			continue
+		}
+		// Рукопожатие - в своей горутине, чтобы медленный клиент не задерживал accept
 		s.wg.Add(1)
-		go func(c net.Conn) {
+		go func(conn net.Conn) {
 			defer s.wg.Done()
-			handleConnection(c, s.cfg, s.logger)
+			if s.handshake(conn, tc) {
+				s.serve(conn)
+			}
 		}(conn)
 	}
 }
 
+// serve обслуживает новое подключение в отдельной горутине: записывает обмен (-record, -pcap),
+// затем передаёт его в handle
+func (s *Server) serve(conn net.Conn) {
+	s.logger.Printf("accepted connection from %s", conn.RemoteAddr())
+	if s.rec != nil {
+		conn = s.rec.Wrap(conn)
+	}
+	if s.pcap != nil {
+		conn = s.pcap.Wrap(conn)
+	}
+	s.wg.Add(1)
+	go func(c net.Conn) {
+		defer s.wg.Done()
+		s.handle(c)
+	}(conn)
+}
+
+// handle обслуживает соединение в выбранном режиме: воспроизведение записи, прокси или эмуляция
+func (s *Server) handle(conn net.Conn) {
+	switch {
+	case s.replay != nil:
+		s.serveReplay(conn)
+	case s.cfg.Upstream != "":
+		s.serveProxy(conn)
+	default:
+		handleConnection(conn, s.cfg, s.logger, s.dev, s.cmds, s.links, s.connCursor())
+	}
+}
+
+// namedConn подменяет адрес собеседника
+type namedConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c namedConn) RemoteAddr() net.Addr { return c.remote }
+
+// removeStaleSocket удаляет сокет, оставшийся от аварийно завершённого запуска; другой файл не трогает
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
 // Stop корректно останавливает сервер: закрывает listener и ждёт хендлер-горутин
 func (s *Server) Stop() {
 	s.mu.Lock()
@@ -75,7 +336,24 @@ func (s *Server) Stop() {
 	if s.ln != nil {
 		_ = s.ln.Close()
 	}
+	if s.pc != nil {
+		_ = s.pc.Close()
+	}
+	if s.pty != nil {
+		// Прерывает чтение в servePTY
+		_ = s.pty.Close()
+	}
 	s.logger.Printf("closing server, waiting for handlers...")
 	s.wg.Wait()
+	if s.learn != nil {
+		s.saveProfile()
+		s.logger.Printf("device profile saved to %s: %d request(s)", s.cfg.Learn, s.learn.Len())
+	}
+	if s.rec != nil {
+		_ = s.rec.Close()
+	}
+	if s.pcap != nil {
+		_ = s.pcap.Close()
+	}
 	s.logger.Printf("server stopped")
 }
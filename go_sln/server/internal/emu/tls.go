@@ -0,0 +1,134 @@
+package emu
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"sln/internal/config"
+	"time"
+)
+
+// handshakeTimeout - сколько ждать TLS-рукопожатия после accept
+const handshakeTimeout = 10 * time.Second
+
+// newTLSConfig собирает настройки TLS эмулятора (-tls): сертификат из -tlscert/-tlskey или,
+// если они не заданы, самоподписанный, созданный при старте. С -tlsclientca клиент обязан
+// предъявить сертификат, подписанный этим CA (взаимная аутентификация)
+func newTLSConfig(cfg *config.Config, logger *log.Logger) (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch {
+	case cfg.TLSCert != "" || cfg.TLSKey != "":
+		if cfg.TLSCert == "" || cfg.TLSKey == "" {
+			return nil, fmt.Errorf("-tlscert and -tlskey must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+		logger.Printf("TLS certificate %s", cfg.TLSCert)
+	default:
+		cert, certPEM, err := selfSigned(cfg.Host)
+		if err != nil {
+			return nil, fmt.Errorf("self-signed certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+		logger.Printf("TLS self-signed certificate generated, sha256=%X", sha256.Sum256(cert.Certificate[0]))
+		if cfg.TLSExport != "" {
+			if err := os.WriteFile(cfg.TLSExport, certPEM, 0o644); err != nil {
+				return nil, err
+			}
+			logger.Printf("TLS certificate exported to %s (use as client -tlsca)", cfg.TLSExport)
+		}
+	}
+	if cfg.TLSClientCA != "" {
+		pool, err := loadCertPool(cfg.TLSClientCA)
+		if err != nil {
+			return nil, err
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+		logger.Printf("TLS client certificates required (CA %s)", cfg.TLSClientCA)
+	}
+	return tc, nil
+}
+
+// selfSigned создаёт самоподписанный сертификат ECDSA P-256 для localhost, 127.0.0.1, ::1
+// и адреса прослушки -host. Возвращает сертификат и его PEM для клиента
+func selfSigned(host string) (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "ttp20 emulator"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !ip.IsUnspecified() && !ip.IsLoopback() {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		}
+	} else if host != "" && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// loadCertPool читает сертификаты CA из PEM-файла
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("%s: no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// handshake выполняет TLS-рукопожатие сразу после accept, чтобы его ошибки (чужой
+// сертификат, клиент без TLS) были видны в логе отдельно от обмена
+func (s *Server) handshake(conn net.Conn, tc *tls.Conn) bool {
+	_ = tc.SetDeadline(time.Now().Add(handshakeTimeout))
+	err := tc.Handshake()
+	_ = tc.SetDeadline(time.Time{})
+	if err != nil {
+		s.logger.Printf("[%s] TLS handshake failed: %v", conn.RemoteAddr(), err)
+		_ = tc.Close()
+		return false
+	}
+	st := tc.ConnectionState()
+	peer := "no client certificate"
+	if len(st.PeerCertificates) > 0 {
+		peer = "client " + st.PeerCertificates[0].Subject.String()
+	}
+	s.logger.Printf("[%s] TLS %s %s, %s", conn.RemoteAddr(), tls.VersionName(st.Version), tls.CipherSuiteName(st.CipherSuite), peer)
+	return true
+}
@@ -0,0 +1,164 @@
+package emu
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// serveUDP - режим UDP (-transport udp): каждый адрес отправителя обслуживается как отдельное
+// соединение. Датаграмма - очередной кусок потока, как при чтении из TCP: кадр может прийти
+// в нескольких датаграммах, а в одной датаграмме может быть несколько кадров.
+// Ответы уходят на адрес отправителя
+func (s *Server) serveUDP() error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	s.pc = pc
+	s.logger.Printf("listening on udp %s", addr)
+
+	var mu sync.Mutex
+	peers := map[string]*udpConn{}
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.close:
+				return nil
+			default:
+				s.logger.Printf("udp read error: %v", err)
+				continue
+			}
+		}
+		key := from.String()
+		// Поиск отправителя и постановка в очередь идут под той же блокировкой, что и закрытие
+		// соединения: датаграмма попадает либо в живое соединение, либо в новое
+		mu.Lock()
+		c, ok := peers[key]
+		if !ok {
+			c = &udpConn{pc: pc, remote: from, in: make(chan []byte, 64), done: make(chan struct{}), stop: s.close, changed: make(chan struct{})}
+			c.closed = func() {
+				mu.Lock()
+				delete(peers, key)
+				// После удаления из peers новых датаграмм в очереди не появится
+				lost := len(c.in)
+				mu.Unlock()
+				if lost > 0 {
+					s.logger.Printf("[%s] connection closed, %d queued datagram(s) dropped", key, lost)
+				}
+			}
+			peers[key] = c
+		}
+		delivered := c.deliver(append([]byte(nil), buf[:n]...))
+		mu.Unlock()
+		if !ok {
+			s.serve(c)
+		}
+		if !delivered {
+			s.logger.Printf("[%s] receive queue full, datagram dropped", key)
+		}
+	}
+}
+
+// udpConn - «соединение» с одним отправителем поверх общего UDP-сокета. Закрытие не трогает
+// сокет, а только забывает отправителя: следующая датаграмма от него начнёт новое соединение
+type udpConn struct {
+	pc     net.PacketConn
+	remote net.Addr
+	in     chan []byte
+	done   chan struct{}
+	stop   <-chan struct{} // закрытие сервера
+	closed func()
+	once   sync.Once
+
+	mu       sync.Mutex
+	deadline time.Time
+	changed  chan struct{} // закрывается при смене дедлайна, чтобы ожидающий Read пересчитал таймер
+	pending  []byte        // остаток датаграммы, не поместившийся в буфер Read
+}
+
+// deliver ставит датаграмму в очередь; false - очередь переполнена (обработчик не успевает)
+func (c *udpConn) deliver(b []byte) bool {
+	select {
+	case c.in <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *udpConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.pending) == 0 {
+		deadline, changed := c.deadline, c.changed
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		c.mu.Unlock()
+		var timeout <-chan time.Time
+		var t *time.Timer
+		if !deadline.IsZero() {
+			t = time.NewTimer(time.Until(deadline))
+			timeout = t.C
+		}
+		var b []byte
+		var err error
+		select {
+		case b = <-c.in:
+		case <-changed:
+			// Дедлайн изменён - ждём заново с новым значением
+		case <-timeout:
+			err = os.ErrDeadlineExceeded
+		case <-c.done:
+			err = net.ErrClosed
+		case <-c.stop:
+			err = net.ErrClosed
+		}
+		if t != nil {
+			t.Stop()
+		}
+		c.mu.Lock()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = b
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *udpConn) Write(b []byte) (int, error) {
+	return c.pc.WriteTo(b, c.remote)
+}
+
+func (c *udpConn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		c.closed()
+	})
+	return nil
+}
+
+func (c *udpConn) LocalAddr() net.Addr  { return c.pc.LocalAddr() }
+func (c *udpConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *udpConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+func (c *udpConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline не нужен: запись в UDP не блокируется
+func (c *udpConn) SetWriteDeadline(t time.Time) error { return nil }
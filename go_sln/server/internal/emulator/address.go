@@ -0,0 +1,25 @@
+package emulator
+
+// NoBroadcast - значение -broadcast, отключающее широковещательный адрес
+const NoBroadcast = -1
+
+// AddressMatch - к кому обращён кадр с точки зрения эмулятора
+type AddressMatch int
+
+const (
+	AddrOther     AddressMatch = iota // кадр другому устройству на шине - молчим
+	AddrOwn                           // кадр эмулятору - выполняем и отвечаем
+	AddrBroadcast                     // широковещательный кадр - выполняем без ответа
+)
+
+// MatchAddress сравнивает адрес кадра с адресом адаптера и широковещательным адресом
+// (NoBroadcast - широковещательных кадров нет)
+func MatchAddress(addr byte, adapterAddr byte, broadcast int) AddressMatch {
+	switch {
+	case addr == adapterAddr:
+		return AddrOwn
+	case broadcast != NoBroadcast && int(addr) == broadcast:
+		return AddrBroadcast
+	}
+	return AddrOther
+}
@@ -0,0 +1,26 @@
+package emulator
+
+import "testing"
+
+func TestMatchAddress(t *testing.T) {
+	tests := []struct {
+		name          string
+		addr, adapter byte
+		broadcast     int
+		want          AddressMatch
+	}{
+		{"own", 0x01, 0x01, 255, AddrOwn},
+		{"other", 0x05, 0x01, 255, AddrOther},
+		{"broadcast", 0xFF, 0x01, 255, AddrBroadcast},
+		{"custom broadcast", 0x00, 0x01, 0, AddrBroadcast},
+		{"broadcast disabled", 0xFF, 0x01, NoBroadcast, AddrOther},
+		// Адрес адаптера, совпадающий с широковещательным, считается собственным: ответ отправляется
+		{"own equals broadcast", 0xFF, 0xFF, 255, AddrOwn},
+		{"broadcast out of byte range", 0x00, 0x01, 256, AddrOther},
+	}
+	for _, tt := range tests {
+		if got := MatchAddress(tt.addr, tt.adapter, tt.broadcast); got != tt.want {
+			t.Errorf("%s: MatchAddress(0x%02X, 0x%02X, %d) = %d, want %d", tt.name, tt.addr, tt.adapter, tt.broadcast, got, tt.want)
+		}
+	}
+}
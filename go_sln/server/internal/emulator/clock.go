@@ -0,0 +1,28 @@
+package emulator
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock - виртуальные часы эмулируемого устройства: системное время плюс смещение,
+// которое задаёт команда установки времени. Общие для всех соединений сервера
+type Clock struct {
+	mu     sync.Mutex
+	offset time.Duration
+}
+
+// Now возвращает текущее время устройства
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+// Set переводит часы устройства на t и возвращает новое смещение относительно системного времени
+func (c *Clock) Set(t time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = time.Until(t)
+	return c.offset
+}
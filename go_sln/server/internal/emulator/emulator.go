@@ -2,41 +2,136 @@ package emulator
 
 import (
 	"encoding/binary"
-	"sln/internal/frame"
+	"errors"
+	"fmt"
+	"sln/ft12"
 	"time"
 )
 
-// BuildTimeResponse строит фрейм-ответ с текущим временем
-// Формат DATA: [0x01] + ASCII("YYYY-MM-DD HH:MM:SS").
-func BuildTimeResponse(reqCtrl byte, reqAddr byte, reqData []byte, crcMode string, adapterAddr byte) []byte {
-	respCtrl := reqCtrl | 0x80 // пометить как ответ
-	respAddr := reqAddr
+// Коды команд прикладного уровня (первый байт DATA)
+const (
+	CmdReadTime    = 0x01 // чтение часов устройства
+	CmdReadArchive = 0x02 // чтение архива; ответ передаётся сегментами (см. ft12.EncodeSegmented)
+	CmdWriteTime   = 0x03 // установка часов: DATA = [0x03] + время в формате -timefmt
+	CmdReadParam   = 0x04 // чтение параметра: DATA = [0x04] [ADDR_LO ADDR_HI]
+	CmdWriteParam  = 0x05 // запись параметра: DATA = [0x05] [ADDR_LO ADDR_HI] [значение]
+)
+
+// DefaultArchiveLen - объём архива в ответе, если запрос не указывает длину
+const DefaultArchiveLen = 1024
 
-	timeStr := time.Now().Format("2006-01-02 15:04:05")
-	payload := append([]byte{0x01}, []byte(timeStr)...)
+// BuildTimeResponse строит фрейм-ответ со временем устройства now от имени adapterAddr
+// Формат DATA: [0x01] + время в формате tc (ASCII "YYYY-MM-DD HH:MM:SS", CP56Time2a или BCD).
+func BuildTimeResponse(codec ft12.Codec, tc ft12.TimeCodec, now time.Time, crcMode string, adapterAddr byte) ([]byte, error) {
+	respCtrl := responseControl()
+	respAddr := adapterAddr
 
-	skel := frame.BuildSkeleton(respCtrl, respAddr, payload)
-	full := frame.AppendChecksum(skel, crcMode)
-	return full
+	payload := append([]byte{CmdReadTime}, tc.Encode(ft12.DeviceTime{Time: now, Summer: now.IsDST()})...)
+
+	skel, err := codec.BuildSkeleton(respCtrl, respAddr, payload)
+	if err != nil {
+		return nil, err
+	}
+	full := codec.AppendChecksum(skel, crcMode)
+	return full, nil
 }
 
-// BuildAckResponse строит простой ACK/echo ответ для неизвестных команд
-func BuildAckResponse(reqCtrl byte, reqAddr byte, reqData []byte, crcMode string, adapterAddr byte) []byte {
-	respCtrl := reqCtrl | 0x80
-	respAddr := reqAddr
+// BuildAckResponse строит простой ACK/echo ответ для неизвестных команд от имени adapterAddr
+// (прежнее поведение эмулятора, включается флагом -echo)
+func BuildAckResponse(codec ft12.Codec, reqData []byte, crcMode string, adapterAddr byte) ([]byte, error) {
+	respCtrl := responseControl()
+	respAddr := adapterAddr
 	cmd := byte(0xFF)
 	if len(reqData) > 0 {
 		cmd = reqData[0]
 	}
 	payload := append([]byte{cmd}, []byte("OK")...)
-	skel := frame.BuildSkeleton(respCtrl, respAddr, payload)
-	full := frame.AppendChecksum(skel, crcMode)
-	return full
+	skel, err := codec.BuildSkeleton(respCtrl, respAddr, payload)
+	if err != nil {
+		return nil, err
+	}
+	full := codec.AppendChecksum(skel, crcMode)
+	return full, nil
+}
+
+// BuildArchiveResponse строит многокадровый ответ на чтение архива.
+// Запрос: [0x02] [LEN_LO LEN_HI] - сколько байт архива вернуть (по умолчанию DefaultArchiveLen).
+// Ответ идёт от имени adapterAddr. DATA каждого кадра: [0x02] [заголовок сегмента] [часть архива]; segSize - байт архива на кадр (0 - максимум)
+func BuildArchiveResponse(codec ft12.Codec, adapterAddr byte, reqData []byte, crcMode string, segSize int) ([][]byte, error) {
+	n := DefaultArchiveLen
+	if len(reqData) >= 3 {
+		n = int(binary.LittleEndian.Uint16(reqData[1:3]))
+	}
+	// Содержимое архива детерминировано, чтобы клиент мог проверить сборку
+	archive := make([]byte, n)
+	for i := range archive {
+		archive[i] = byte(i)
+	}
+	tmpl := ft12.Frame{
+		Control:      responseControl(),
+		Address:      adapterAddr,
+		ChecksumKind: ft12.ChecksumKind(crcMode),
+		Dialect:      codec.Dialect,
+	}
+	return ft12.EncodeSegmented(tmpl, []byte{CmdReadArchive}, archive, segSize)
+}
+
+// ApplyWriteTime разбирает запрос установки времени [0x03] + время и переводит часы устройства.
+// Возвращает новое смещение часов относительно системного времени
+func ApplyWriteTime(tc ft12.TimeCodec, clock *Clock, reqData []byte) (time.Duration, error) {
+	if len(reqData) < 1 || reqData[0] != CmdWriteTime {
+		return 0, fmt.Errorf("not a write-time request")
+	}
+	dt, err := tc.Decode(reqData[1:])
+	if err != nil {
+		return 0, err
+	}
+	return clock.Set(dt.Time), nil
+}
+
+// BuildErrorResponse строит отрицательный ответ на команду cmd: DATA = [cmd | 0x80] [code]
+func BuildErrorResponse(codec ft12.Codec, cmd byte, code ft12.ErrorCode, crcMode string, adapterAddr byte) ([]byte, error) {
+	skel, err := codec.BuildSkeleton(responseControl(), adapterAddr, ft12.ErrorPayload(cmd, code))
+	if err != nil {
+		return nil, err
+	}
+	return codec.AppendChecksum(skel, crcMode), nil
+}
+
+// ErrorCode подбирает код отрицательного ответа для ошибки выполнения команды
+func ErrorCode(err error) ft12.ErrorCode {
+	var devErr *ft12.DeviceError
+	switch {
+	case errors.As(err, &devErr):
+		return devErr.Code
+	case errors.Is(err, ErrNoRegister):
+		return ft12.ErrCodeBadAddress
+	case errors.Is(err, ErrAccess):
+		return ft12.ErrCodeAccessDenied
+	}
+	// Неверный формат запроса, неразбираемое время и т.п.
+	return ft12.ErrCodeBadLength
+}
+
+// BuildConfirm строит положительное подтверждение команды записи (односимвольное 0xE5)
+func BuildConfirm() []byte {
+	return []byte{ft12.SingleCharAck}
+}
+
+// BuildLinkResponse строит ответ на короткий кадр канального уровня.
+// Запрос состояния канала (FC 9) -> короткий кадр "состояние канала" (FC 11),
+// сброс канала и прочие запросы -> односимвольное подтверждение 0xE5
+func BuildLinkResponse(reqCtrl byte, reqAddr byte) []byte {
+	switch ft12.ParseControl(reqCtrl).Func {
+	case ft12.FuncLinkStatus:
+		return ft12.BuildShort(ft12.ControlField{Func: ft12.FuncRespLinkStatus}.Byte(), reqAddr)
+	default:
+		return []byte{ft12.SingleCharAck}
+	}
 }
 
-// putUint16LE возвращает 2 байта little-endian (утилитная функция).
-func putUint16LE(v uint16) []byte {
-	b := make([]byte, 2)
-	binary.LittleEndian.PutUint16(b, v)
-	return b
+// responseControl формирует CONTROL ответа вторичной станции с данными (FC 8).
+// Бит DIR оставлен как признак ответа, который исторически ставит эмулятор
+func responseControl() byte {
+	return ft12.ControlField{DIR: true, Func: ft12.FuncRespUserData}.Byte()
 }
@@ -0,0 +1,173 @@
+package emulator
+
+import (
+	"fmt"
+	"log"
+	"sln/ft12"
+	"time"
+)
+
+// Device - эмулируемый прибор: параметры кадров и состояние, общее для всех соединений.
+// Обработчики команд получают его вместе с запросом
+type Device struct {
+	Codec       ft12.Codec
+	CRCMode     string
+	Addr        byte // адрес адаптера, от имени которого строятся ответы
+	TimeCodec   ft12.TimeCodec
+	Clock       *Clock
+	Registers   *Registers
+	SegmentSize int // байт полезных данных на кадр в многокадровых ответах (0 - максимум)
+	Logger      *log.Logger
+}
+
+// Request - запрос прикладного уровня (кадр переменной длины)
+type Request struct {
+	Peer  string // адрес клиента для логов
+	Frame *ft12.Frame
+	Ctrl  ft12.ControlField
+	Cmd   byte   // первый байт DATA (0, если DATA пустое)
+	Data  []byte // DATA целиком, вместе с кодом команды
+	// TimeShift - сдвиг часов прибора в ответе (шаг сценария с time_offset)
+	TimeShift time.Duration
+}
+
+// Handler выполняет одну команду и возвращает кадры ответа.
+// Ошибка превращается в отрицательный ответ (см. ErrorCode); отправкой, задержкой
+// и тестовыми искажениями занимается общий конвейер соединения
+type Handler interface {
+	Handle(dev *Device, req *Request) ([][]byte, error)
+}
+
+// HandlerFunc позволяет использовать функцию как Handler
+type HandlerFunc func(dev *Device, req *Request) ([][]byte, error)
+
+func (f HandlerFunc) Handle(dev *Device, req *Request) ([][]byte, error) { return f(dev, req) }
+
+// Handlers - реестр обработчиков по коду команды
+type Handlers struct {
+	byCmd map[byte]Handler
+	// Fallback вызывается для незарегистрированных команд
+	Fallback Handler
+}
+
+// NewHandlers создаёт пустой реестр; незарегистрированные команды получают ошибку "unknown command"
+func NewHandlers() *Handlers {
+	return &Handlers{byCmd: map[byte]Handler{}, Fallback: HandlerFunc(unknownCommand)}
+}
+
+// Register добавляет обработчик команды cmd. Повторная регистрация - ошибка
+func (h *Handlers) Register(cmd byte, handler Handler) error {
+	if cmd&ft12.ErrorFlag != 0 {
+		return fmt.Errorf("command 0x%02X: high bit is reserved for error responses", cmd)
+	}
+	if _, dup := h.byCmd[cmd]; dup {
+		return fmt.Errorf("command 0x%02X already registered", cmd)
+	}
+	h.byCmd[cmd] = handler
+	return nil
+}
+
+// Lookup возвращает обработчик команды или Fallback
+func (h *Handlers) Lookup(cmd byte) Handler {
+	if handler, ok := h.byCmd[cmd]; ok {
+		return handler
+	}
+	return h.Fallback
+}
+
+// DefaultHandlers регистрирует встроенные команды прибора.
+// legacyEcho включает прежний ответ эхом cmd + "OK" на неизвестные команды
+func DefaultHandlers(legacyEcho bool) *Handlers {
+	h := NewHandlers()
+	for cmd, handler := range map[byte]HandlerFunc{
+		CmdReadTime:    readTime,
+		CmdReadArchive: readArchive,
+		CmdWriteTime:   writeTime,
+		CmdReadParam:   readParam,
+		CmdWriteParam:  writeParam,
+	} {
+		if err := h.Register(cmd, handler); err != nil {
+			panic(err)
+		}
+	}
+	if legacyEcho {
+		h.Fallback = HandlerFunc(echo)
+	}
+	return h
+}
+
+func readTime(dev *Device, req *Request) ([][]byte, error) {
+	dev.Logger.Printf("[%s] read-time request (ctrl=[%s] addr=0x%02X)", req.Peer, req.Ctrl, req.Frame.Address)
+	resp, err := BuildTimeResponse(dev.Codec, dev.TimeCodec, dev.Clock.Now().Add(req.TimeShift), dev.CRCMode, dev.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{resp}, nil
+}
+
+// writeTime - установка часов: подтверждение 0xE5 или ошибка "bad length", если время не разобрать
+func writeTime(dev *Device, req *Request) ([][]byte, error) {
+	offset, err := ApplyWriteTime(dev.TimeCodec, dev.Clock, req.Data)
+	if err != nil {
+		return nil, err
+	}
+	dev.Logger.Printf("[%s] write-time request (ctrl=[%s] addr=0x%02X) - clock offset now %s", req.Peer, req.Ctrl, req.Frame.Address, offset.Round(time.Millisecond))
+	return [][]byte{BuildConfirm()}, nil
+}
+
+// readArchive - чтение архива: ответ не помещается в один кадр и уходит сегментами
+func readArchive(dev *Device, req *Request) ([][]byte, error) {
+	frames, err := BuildArchiveResponse(dev.Codec, dev.Addr, req.Data, dev.CRCMode, dev.SegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	dev.Logger.Printf("[%s] read-archive request (ctrl=[%s] addr=0x%02X) - sending %d segment(s)", req.Peer, req.Ctrl, req.Frame.Address, len(frames))
+	return frames, nil
+}
+
+// readParam - чтение параметра из карты регистров; нет регистра или нет доступа - отрицательный ответ
+func readParam(dev *Device, req *Request) ([][]byte, error) {
+	regAddr, err := ParamAddress(req.Data)
+	if err != nil {
+		return nil, err
+	}
+	reg, raw, err := dev.Registers.Read(regAddr)
+	if err != nil {
+		return nil, err
+	}
+	dev.Logger.Printf("[%s] read-param %q (reg=%d ctrl=[%s]): % X", req.Peer, reg.Name, regAddr, req.Ctrl, raw)
+	resp, err := BuildParamResponse(dev.Codec, regAddr, raw, dev.CRCMode, dev.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{resp}, nil
+}
+
+// writeParam - запись параметра: подтверждение 0xE5, ошибка адреса/доступа/длины - отрицательный ответ
+func writeParam(dev *Device, req *Request) ([][]byte, error) {
+	regAddr, err := ParamAddress(req.Data)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := dev.Registers.Write(regAddr, req.Data[3:])
+	if err != nil {
+		return nil, err
+	}
+	dev.Logger.Printf("[%s] write-param %q (reg=%d ctrl=[%s]): % X", req.Peer, reg.Name, regAddr, req.Ctrl, req.Data[3:])
+	return [][]byte{BuildConfirm()}, nil
+}
+
+func unknownCommand(dev *Device, req *Request) ([][]byte, error) {
+	dev.Logger.Printf("[%s] unknown cmd 0x%02X (ctrl=[%s]) - sending error", req.Peer, req.Cmd, req.Ctrl)
+	return nil, ft12.ErrUnknownCommand
+}
+
+// echo - прежнее поведение: ACK/echo на любую неизвестную команду
+func echo(dev *Device, req *Request) ([][]byte, error) {
+	dev.Logger.Printf("[%s] generic/unknown cmd 0x%02X (ctrl=[%s]) - sending ACK", req.Peer, req.Cmd, req.Ctrl)
+	resp, err := BuildAckResponse(dev.Codec, req.Data, dev.CRCMode, dev.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{resp}, nil
+}
@@ -0,0 +1,112 @@
+package emulator
+
+import (
+	"bytes"
+	"sln/ft12"
+	"sync"
+)
+
+// LinkState - состояние канала вторичной станции для одного адреса: ожидаемый FCB,
+// последний новый запрос и отправленный на него ответ. FCB контролируется только после
+// сброса канала (FC 0): повтор тех же байт запроса с тем же FCB означает, что ответ
+// потерялся, - вместо повторного выполнения команды отправляется сохранённый ответ
+type LinkState struct {
+	mu    sync.Mutex
+	reset bool     // был сброс канала: ведущий и станция согласовали FCB
+	fcb   bool     // FCB последнего принятого запроса
+	valid bool     // FCB уже известен (был запрос с FCV=1 после сброса канала)
+	req   []byte   // байты последнего нового запроса
+	last  [][]byte // последний ответ (копии кадров без тестовых искажений)
+}
+
+// Reset сбрасывает канал (FC 0 "сброс удалённого канала"): следующий запрос считается новым,
+// а с него FCB контролируется
+func (l *LinkState) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reset = true
+	l.forget()
+}
+
+// Drop забывает состояние канала вместе со сбросом (сеанс ведущего закончился):
+// до следующего FC 0 запросы с FCV=1 считаются новыми
+func (l *LinkState) Drop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reset = false
+	l.forget()
+}
+
+func (l *LinkState) forget() {
+	l.valid = false
+	l.req = nil
+	l.last = nil
+}
+
+// Duplicate проверяет FCB запроса frame и запоминает запрос.
+// Возвращает true, если это повтор предыдущего запроса (тот же FCB и те же байты)
+// и для него есть сохранённый ответ. Запросы с FCV=0 и все запросы до сброса канала
+// FCB не контролируются и всегда выполняются заново: новый ведущий начинает с FCB=1,
+// и его первый запрос нельзя принять за повтор запроса предыдущего сеанса
+func (l *LinkState) Duplicate(ctrl ft12.ControlField, frame []byte) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !ctrl.FCV || !l.reset {
+		l.forget()
+		return false
+	}
+	if l.valid && ctrl.FCB == l.fcb && l.last != nil && bytes.Equal(frame, l.req) {
+		return true
+	}
+	l.fcb = ctrl.FCB
+	l.valid = true
+	l.req = append([]byte(nil), frame...)
+	l.last = nil
+	return false
+}
+
+// Remember сохраняет копию ответа для повтора
+func (l *LinkState) Remember(frames [][]byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last = make([][]byte, len(frames))
+	for i, f := range frames {
+		l.last[i] = append([]byte(nil), f...)
+	}
+}
+
+// Last возвращает копию сохранённого ответа (вызывающий может её изменять)
+func (l *LinkState) Last() [][]byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([][]byte, len(l.last))
+	for i, f := range l.last {
+		out[i] = append([]byte(nil), f...)
+	}
+	return out
+}
+
+// Links - состояния канала по адресам станции. Хранятся в сервере, а не в соединении:
+// ведущий, не дождавшийся ответа, может переподключиться и повторить запрос уже по новому
+// соединению, и этот повтор тоже должен быть узнан
+type Links struct {
+	mu    sync.Mutex
+	state map[byte]*LinkState
+}
+
+// NewLinks создаёт пустой набор состояний канала
+func NewLinks() *Links {
+	return &Links{state: map[byte]*LinkState{}}
+}
+
+// Get возвращает состояние канала адреса addr, создавая его при первом обращении
+func (l *Links) Get(addr byte) *LinkState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, ok := l.state[addr]
+	if !ok {
+		st = &LinkState{}
+		l.state[addr] = st
+	}
+	return st
+}
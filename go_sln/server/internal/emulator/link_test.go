@@ -0,0 +1,126 @@
+package emulator
+
+import (
+	"bytes"
+	"sln/ft12"
+	"testing"
+)
+
+// linkStep - одно действие с состоянием канала: сброс, забывание сеанса или запрос
+type linkStep struct {
+	reset, drop bool
+	fcb, fcv    bool
+	frame       string // байты запроса (достаточно различать их между собой)
+	dup         bool   // ожидаемый результат Duplicate
+}
+
+func TestLinkStateDuplicate(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []linkStep
+	}{
+		// Новый ведущий начинает с FCB=1 без сброса: его запросы всегда выполняются
+		{"no reset", []linkStep{
+			{fcb: true, fcv: true, frame: "set 2030"},
+			{fcb: true, fcv: true, frame: "set 2030"},
+			{fcb: true, fcv: true, frame: "set now"},
+		}},
+		{"repeat after reset", []linkStep{
+			{reset: true},
+			{fcb: true, fcv: true, frame: "read"},
+			{fcb: true, fcv: true, frame: "read", dup: true},
+			{fcb: true, fcv: true, frame: "read", dup: true},
+			{fcb: false, fcv: true, frame: "read"},
+			{fcb: false, fcv: true, frame: "read", dup: true},
+		}},
+		// Тот же FCB, но другой запрос - не повтор
+		{"same FCB other request", []linkStep{
+			{reset: true},
+			{fcb: true, fcv: true, frame: "read"},
+			{fcb: true, fcv: true, frame: "write"},
+			{fcb: true, fcv: true, frame: "write", dup: true},
+		}},
+		{"FCV=0", []linkStep{
+			{reset: true},
+			{fcb: false, fcv: false, frame: "read"},
+			{fcb: false, fcv: false, frame: "read"},
+			// FCV=0 между запросами забывает сохранённый ответ
+			{fcb: true, fcv: true, frame: "read"},
+			{fcb: false, fcv: false, frame: "status"},
+			{fcb: true, fcv: true, frame: "read"},
+		}},
+		{"reset between", []linkStep{
+			{reset: true},
+			{fcb: true, fcv: true, frame: "read"},
+			{reset: true},
+			{fcb: true, fcv: true, frame: "read"},
+		}},
+		// Конец сеанса: следующий ведущий снова работает без контроля FCB до своего сброса
+		{"drop", []linkStep{
+			{reset: true},
+			{fcb: true, fcv: true, frame: "read"},
+			{drop: true},
+			{fcb: true, fcv: true, frame: "read"},
+			{fcb: true, fcv: true, frame: "read"},
+			{reset: true},
+			{fcb: true, fcv: true, frame: "read"},
+			{fcb: true, fcv: true, frame: "read", dup: true},
+		}},
+	}
+	for _, tt := range tests {
+		var l LinkState
+		for i, s := range tt.steps {
+			switch {
+			case s.reset:
+				l.Reset()
+				continue
+			case s.drop:
+				l.Drop()
+				continue
+			}
+			ctrl := ft12.ControlField{PRM: true, FCB: s.fcb, FCV: s.fcv, Func: ft12.FuncUserData}
+			if got := l.Duplicate(ctrl, []byte(s.frame)); got != s.dup {
+				t.Errorf("%s: step %d (%q FCB=%v FCV=%v): duplicate = %v, want %v", tt.name, i, s.frame, s.fcb, s.fcv, got, s.dup)
+			}
+			if !s.dup {
+				l.Remember([][]byte{[]byte("response to " + s.frame)})
+			}
+		}
+	}
+}
+
+// Без сохранённого ответа (action=silent, широковещательный запрос) повтор выполняется заново;
+// повтор получает копию ответа, которую можно искажать
+func TestLinkStateLast(t *testing.T) {
+	var l LinkState
+	l.Reset()
+	ctrl := ft12.ControlField{PRM: true, FCB: true, FCV: true, Func: ft12.FuncUserData}
+	req := []byte{0x68, 0x03, 0x68, 0x73, 0x01, 0x01, 0x75, 0x16}
+	if l.Duplicate(ctrl, req) || l.Duplicate(ctrl, req) {
+		t.Fatalf("duplicate without a remembered response")
+	}
+	resp := []byte{0xE5}
+	l.Remember([][]byte{resp})
+	resp[0] = 0 // Remember хранит копию
+	if !l.Duplicate(ctrl, req) {
+		t.Fatalf("repeat not detected")
+	}
+	last := l.Last()
+	if len(last) != 1 || !bytes.Equal(last[0], []byte{0xE5}) {
+		t.Fatalf("Last = % X, want E5", last)
+	}
+	last[0][0] = 0
+	if got := l.Last(); got[0][0] != 0xE5 {
+		t.Errorf("Last returned the stored response instead of a copy")
+	}
+}
+
+func TestLinks(t *testing.T) {
+	links := NewLinks()
+	if links.Get(1) != links.Get(1) {
+		t.Errorf("Get(1) returned different states")
+	}
+	if links.Get(1) == links.Get(2) {
+		t.Errorf("addresses 1 and 2 share a state")
+	}
+}
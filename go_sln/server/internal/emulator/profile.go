@@ -0,0 +1,252 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sln/ft12"
+	"sln/ft12/capture"
+	"sync"
+	"time"
+)
+
+// Профиль прибора снимается в режиме обучения (прокси с -learn): для каждого запроса
+// (команда, адрес, DATA) сохраняются кадры ответа настоящего прибора. Эмулятор с -profile
+// отвечает этими кадрами, подставляя в ответ на чтение времени текущие часы. Пример файла:
+//
+//	{
+//	  "device": "10.0.0.20:9000",
+//	  "learned": "2026-10-17T15:20:00Z",
+//	  "dialect": "simplified",
+//	  "timefmt": "bcd",
+//	  "entries": [
+//	    {"cmd": 4, "addr": 1, "request": "04 01 00", "seen": 3,
+//	     "responses": ["68 11 68 88 01 04 01 00 54 54 52 32 30 2D 30 30 30 31 32 33 3D 16"]},
+//	    {"cmd": 3, "addr": 1, "request": "03 10 06 15 17 10 26", "seen": 1, "responses": ["E5"]}
+//	  ]
+//	}
+//
+// Ответы хранятся кадрами целиком: вместе с CONTROL, адресом и алгоритмом контрольной суммы
+// прибора, поэтому воспроизводятся и его особенности (флаги ACD/DFC, нестандартные ответы).
+// Если точного запроса в профиле нет, для установки времени (её DATA меняется при каждом
+// запросе) берётся последний ответ на ту же команду по тому же адресу; остальные запросы
+// без точного совпадения выполняет обычный эмулятор
+
+// Profile - профиль прибора
+type Profile struct {
+	Device     string          `json:"device"`  // адрес прибора, с которого снят профиль
+	Learned    time.Time       `json:"learned"` // время последнего изменения
+	Dialect    string          `json:"dialect"` // заголовок кадров прибора (-dialect при обучении)
+	TimeFormat string          `json:"timefmt"` // формат времени прибора (-timefmt при обучении)
+	Entries    []*ProfileEntry `json:"entries"`
+
+	mu      sync.Mutex
+	exact   map[string]*ProfileEntry // адрес + DATA запроса
+	lastCmd map[[2]byte]*ProfileEntry
+	save    sync.Mutex // одна запись файла за раз: снимок и переименование идут в одном порядке
+}
+
+// ProfileEntry - запрос и кадры ответа прибора на него
+type ProfileEntry struct {
+	Cmd       int                `json:"cmd"`
+	Addr      int                `json:"addr"`
+	Request   capture.HexBytes   `json:"request"` // DATA запроса вместе с кодом команды
+	Responses []capture.HexBytes `json:"responses"`
+	Seen      int                `json:"seen"` // сколько раз запрос встретился при обучении
+}
+
+// NewProfile создаёт пустой профиль прибора device
+func NewProfile(device, dialect, timeFormat string) *Profile {
+	p := &Profile{Device: device, Dialect: dialect, TimeFormat: timeFormat, Entries: []*ProfileEntry{}}
+	p.reindex()
+	return p
+}
+
+// LoadProfile читает и проверяет профиль из JSON-файла
+func LoadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParseProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ParseProfile разбирает и проверяет профиль
+func ParseProfile(data []byte) (*Profile, error) {
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	dialect, err := ft12.ParseDialect(p.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range p.Entries {
+		switch {
+		case len(e.Request) == 0:
+			return nil, fmt.Errorf("entry %d: empty request", i)
+		case e.Cmd != int(e.Request[0]):
+			return nil, fmt.Errorf("entry %d: cmd %d does not match request % X", i, e.Cmd, []byte(e.Request))
+		case e.Addr < 0 || e.Addr > 0xFF:
+			return nil, fmt.Errorf("entry %d: addr %d out of range 0..255", i, e.Addr)
+		case len(e.Responses) == 0:
+			return nil, fmt.Errorf("entry %d: no responses", i)
+		}
+		for j, r := range e.Responses {
+			if _, err := (ft12.Codec{Dialect: dialect}).Decode(r); err != nil {
+				return nil, fmt.Errorf("entry %d: response %d: %w", i, j, err)
+			}
+		}
+	}
+	p.reindex()
+	return &p, nil
+}
+
+func (p *Profile) reindex() {
+	p.exact = map[string]*ProfileEntry{}
+	p.lastCmd = map[[2]byte]*ProfileEntry{}
+	for _, e := range p.Entries {
+		p.exact[profileKey(byte(e.Addr), e.Request)] = e
+		p.lastCmd[[2]byte{byte(e.Addr), byte(e.Cmd)}] = e
+	}
+}
+
+func profileKey(addr byte, req []byte) string {
+	return string(append([]byte{addr}, req...))
+}
+
+// Learn запоминает ответ прибора на запрос req по адресу addr; прежний ответ на тот же
+// запрос заменяется. Возвращает true, если запрос новый
+func (p *Profile) Learn(addr byte, req []byte, resp [][]byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	responses := make([]capture.HexBytes, len(resp))
+	for i, r := range resp {
+		responses[i] = append(capture.HexBytes(nil), r...)
+	}
+	p.Learned = time.Now().UTC()
+	key := profileKey(addr, req)
+	e, ok := p.exact[key]
+	if !ok {
+		e = &ProfileEntry{Cmd: int(req[0]), Addr: int(addr), Request: append(capture.HexBytes(nil), req...)}
+		p.Entries = append(p.Entries, e)
+		p.exact[key] = e
+	}
+	e.Responses = responses
+	e.Seen++
+	p.lastCmd[[2]byte{addr, req[0]}] = e
+	return !ok
+}
+
+// anyDataCmds - команды, на которые прибор отвечает одинаково при любых DATA запроса
+var anyDataCmds = map[byte]bool{CmdWriteTime: true}
+
+// Lookup ищет ответ на запрос: сначала точный, затем, для команд из anyDataCmds, последний
+// на ту же команду по тому же адресу. exact - найден точный запрос
+func (p *Profile) Lookup(addr byte, req []byte) (resp [][]byte, exact bool, ok bool) {
+	if len(req) == 0 {
+		return nil, false, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, exact := p.exact[profileKey(addr, req)]
+	if !exact {
+		if !anyDataCmds[req[0]] {
+			return nil, false, false
+		}
+		if e, ok = p.lastCmd[[2]byte{addr, req[0]}]; !ok {
+			return nil, false, false
+		}
+	}
+	resp = make([][]byte, len(e.Responses))
+	for i, r := range e.Responses {
+		resp[i] = append([]byte(nil), r...)
+	}
+	return resp, exact, true
+}
+
+// Len возвращает число запросов в профиле
+func (p *Profile) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.Entries)
+}
+
+// Save записывает профиль в файл атомарно (через временный файл), чтобы при аварийном
+// завершении обучения не остался обрезанный JSON. Сохранения из разных соединений
+// выполняются по очереди, поэтому более старый снимок не перезапишет более новый
+func (p *Profile) Save(path string) error {
+	p.save.Lock()
+	defer p.save.Unlock()
+	p.mu.Lock()
+	data, err := json.MarshalIndent(p, "", "  ")
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Handler отвечает на запросы кадрами из профиля; запросы, которых в профиле нет, передаются
+// обработчикам next. В ответе на чтение времени время заменяется текущим временем часов
+// эмулятора, а установка времени, подтверждённая прибором, переводит эти часы
+func (p *Profile) Handler(next *Handlers) Handler {
+	return HandlerFunc(func(dev *Device, req *Request) ([][]byte, error) {
+		frames, exact, ok := p.Lookup(req.Frame.Address, req.Data)
+		if !ok {
+			dev.Logger.Printf("[%s] cmd 0x%02X addr 0x%02X not in profile - emulating", req.Peer, req.Cmd, req.Frame.Address)
+			return next.Lookup(req.Cmd).Handle(dev, req)
+		}
+		match := "exact"
+		if !exact {
+			match = "same command"
+		}
+		dev.Logger.Printf("[%s] profile %s %s (ctrl=[%s] addr=0x%02X) - %d frame(s)", req.Peer, ft12.CommandName(req.Cmd), match, req.Ctrl, req.Frame.Address, len(frames))
+		for i, f := range frames {
+			frames[i] = refreshTime(dev, req, f)
+		}
+		if req.Cmd == CmdWriteTime && len(frames) > 0 && frames[0][0] == ft12.SingleCharAck {
+			if _, err := ApplyWriteTime(dev.TimeCodec, dev.Clock, req.Data); err != nil {
+				dev.Logger.Printf("[%s] write-time: %v - clock unchanged", req.Peer, err)
+			}
+		}
+		return frames, nil
+	})
+}
+
+// refreshTime подставляет текущее время часов эмулятора в записанный ответ на чтение времени.
+// CONTROL, адрес и алгоритм контрольной суммы остаются прежними; другие кадры не меняются
+func refreshTime(dev *Device, req *Request, frame []byte) []byte {
+	codec := dev.Codec
+	codec.Strict = false
+	f, err := codec.Decode(frame)
+	if err != nil || f.Kind != ft12.KindVariable || len(f.Data) < 2 || f.Data[0] != CmdReadTime {
+		return frame
+	}
+	now := dev.Clock.Now().Add(req.TimeShift)
+	f.Data = append([]byte{CmdReadTime}, dev.TimeCodec.Encode(ft12.DeviceTime{Time: now, Summer: now.IsDST()})...)
+	out, err := f.Encode()
+	if err != nil || bytes.Equal(out, frame) {
+		return frame
+	}
+	return out
+}
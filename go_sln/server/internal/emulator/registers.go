@@ -0,0 +1,98 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sln/ft12"
+	"sln/ft12/regmap"
+	"sync"
+)
+
+var (
+	ErrNoRegister  = errors.New("no such register")
+	ErrAccess      = errors.New("register access denied")
+	ErrParamFormat = errors.New("malformed parameter request")
+)
+
+// Registers - текущие значения регистров эмулируемого прибора, общие для всех соединений.
+// Значения хранятся в сыром виде, как они передаются по линии
+type Registers struct {
+	mu     sync.Mutex
+	m      *regmap.Map
+	values map[uint16][]byte
+}
+
+// NewRegisters заполняет регистры начальными значениями из карты; nil - прибор без регистров
+func NewRegisters(m *regmap.Map) *Registers {
+	r := &Registers{m: m, values: map[uint16][]byte{}}
+	if m == nil {
+		return r
+	}
+	for _, reg := range m.Registers {
+		// Начальные значения проверены при загрузке карты
+		raw, _ := reg.InitialRaw()
+		r.values[reg.Address] = raw
+	}
+	return r
+}
+
+// Read возвращает сырое значение регистра
+func (r *Registers) Read(addr uint16) (*regmap.Register, []byte, error) {
+	reg, err := r.lookup(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !reg.Readable() {
+		return reg, nil, fmt.Errorf("%w: %q is write-only", ErrAccess, reg.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return reg, append([]byte(nil), r.values[addr]...), nil
+}
+
+// Write записывает сырое значение регистра; длина должна совпадать с типом
+func (r *Registers) Write(addr uint16, raw []byte) (*regmap.Register, error) {
+	reg, err := r.lookup(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !reg.Writable() {
+		return reg, fmt.Errorf("%w: %q is read-only", ErrAccess, reg.Name)
+	}
+	if len(raw) != reg.Size() {
+		return reg, fmt.Errorf("%w: %q wants %d bytes, got %d", ErrParamFormat, reg.Name, reg.Size(), len(raw))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[addr] = append([]byte(nil), raw...)
+	return reg, nil
+}
+
+func (r *Registers) lookup(addr uint16) (*regmap.Register, error) {
+	if r.m != nil {
+		if reg, ok := r.m.ByAddress(addr); ok {
+			return reg, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: address %d", ErrNoRegister, addr)
+}
+
+// ParamAddress извлекает адрес регистра из запроса [cmd] [ADDR_LO ADDR_HI] ...
+func ParamAddress(reqData []byte) (uint16, error) {
+	if len(reqData) < 3 {
+		return 0, ErrParamFormat
+	}
+	return binary.LittleEndian.Uint16(reqData[1:3]), nil
+}
+
+// BuildParamResponse строит ответ на чтение параметра:
+// DATA = [0x04] [ADDR_LO ADDR_HI] [сырое значение]
+func BuildParamResponse(codec ft12.Codec, addr uint16, raw []byte, crcMode string, adapterAddr byte) ([]byte, error) {
+	payload := append([]byte{CmdReadParam, byte(addr), byte(addr >> 8)}, raw...)
+	skel, err := codec.BuildSkeleton(responseControl(), adapterAddr, payload)
+	if err != nil {
+		return nil, err
+	}
+	return codec.AppendChecksum(skel, crcMode), nil
+}
@@ -0,0 +1,201 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Сценарий задаёт поведение эмулятора на последовательность запросов вместо случайных
+// вероятностей -badcrc/-fragment. Пример файла:
+//
+//	{
+//	  "cursor": "connection",
+//	  "loop": true,
+//	  "steps": [
+//	    {"cmd": 1, "repeat": 3},
+//	    {"cmd": 1, "fault": "badcrc"},
+//	    {"cmd": 1, "action": "silent", "repeat": 2},
+//	    {"cmd": 1, "time_offset": "2h"}
+//	  ]
+//	}
+//
+// Запрос сопоставляется только с текущим шагом; не подошедший запрос обрабатывается обычно
+// и курсор не сдвигает. Короткие кадры канального уровня и повторы по FCB в сценарии не участвуют
+
+// Действия шага
+const (
+	ActionRespond = "respond" // выполнить команду обычным обработчиком (по умолчанию)
+	ActionSilent  = "silent"  // не отвечать
+	ActionError   = "error"   // отрицательный ответ с кодом error_code (по умолчанию busy)
+)
+
+// Искажения ответа шага
+const (
+	FaultBadCRC   = "badcrc"   // испортить контрольную сумму
+	FaultFragment = "fragment" // отправить ответ двумя частями
+)
+
+// Область курсора сценария
+const (
+	CursorConnection = "connection" // у каждого соединения свой курсор (по умолчанию)
+	CursorGlobal     = "global"     // один курсор на весь сервер
+)
+
+// Scenario - сценарий эмулятора
+type Scenario struct {
+	Cursor   string  `json:"cursor"`
+	Loop     bool    `json:"loop"`      // после последнего шага вернуться к LoopFrom
+	LoopFrom int     `json:"loop_from"` // индекс шага начала цикла
+	Steps    []*Step `json:"steps"`
+}
+
+// Step - шаг сценария
+type Step struct {
+	Name       string `json:"name"`        // для логов
+	Cmd        *int   `json:"cmd"`         // код команды; нет - любая
+	Addr       *int   `json:"addr"`        // адрес кадра; нет - любой
+	Repeat     int    `json:"repeat"`      // сколько совпавших запросов обслуживает шаг (0 - 1)
+	Action     string `json:"action"`      // respond | silent | error
+	ErrorCode  int    `json:"error_code"`  // для action=error
+	Fault      string `json:"fault"`       // "" | badcrc | fragment
+	DelayMs    int    `json:"delay_ms"`    // дополнительная задержка ответа
+	TimeOffset string `json:"time_offset"` // сдвиг часов прибора в ответе, например "2h" или "-90s"
+
+	timeShift time.Duration
+}
+
+// LoadScenario читает и проверяет сценарий из JSON-файла
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := ParseScenario(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// ParseScenario разбирает и проверяет сценарий
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, err
+	}
+	switch sc.Cursor {
+	case "":
+		sc.Cursor = CursorConnection
+	case CursorConnection, CursorGlobal:
+	default:
+		return nil, fmt.Errorf("unknown cursor %q (want connection | global)", sc.Cursor)
+	}
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("scenario has no steps")
+	}
+	if sc.LoopFrom < 0 || sc.LoopFrom >= len(sc.Steps) {
+		return nil, fmt.Errorf("loop_from %d out of range 0..%d", sc.LoopFrom, len(sc.Steps)-1)
+	}
+	for i, st := range sc.Steps {
+		if err := st.validate(); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+	return &sc, nil
+}
+
+func (st *Step) validate() error {
+	switch st.Action {
+	case "":
+		st.Action = ActionRespond
+	case ActionRespond, ActionSilent:
+	case ActionError:
+		if st.ErrorCode == 0 {
+			st.ErrorCode = 0x04 // busy
+		}
+		if st.ErrorCode < 1 || st.ErrorCode > 0xFF {
+			return fmt.Errorf("error_code %d out of range 1..255", st.ErrorCode)
+		}
+	default:
+		return fmt.Errorf("unknown action %q (want respond | silent | error)", st.Action)
+	}
+	switch st.Fault {
+	case "", FaultBadCRC, FaultFragment:
+	default:
+		return fmt.Errorf("unknown fault %q (want badcrc | fragment)", st.Fault)
+	}
+	if st.Repeat < 0 || st.DelayMs < 0 {
+		return fmt.Errorf("repeat and delay_ms must not be negative")
+	}
+	if st.Repeat == 0 {
+		st.Repeat = 1
+	}
+	if st.Cmd != nil && (*st.Cmd < 0 || *st.Cmd > 0xFF) || st.Addr != nil && (*st.Addr < 0 || *st.Addr > 0xFF) {
+		return fmt.Errorf("cmd and addr must be in range 0..255")
+	}
+	if st.TimeOffset != "" {
+		d, err := time.ParseDuration(st.TimeOffset)
+		if err != nil {
+			return fmt.Errorf("time_offset: %v", err)
+		}
+		st.timeShift = d
+	}
+	return nil
+}
+
+// Matches проверяет, подходит ли запрос под шаг
+func (st *Step) Matches(cmd byte, addr byte) bool {
+	return (st.Cmd == nil || *st.Cmd == int(cmd)) && (st.Addr == nil || *st.Addr == int(addr))
+}
+
+// TimeShift возвращает сдвиг часов прибора для ответа шага
+func (st *Step) TimeShift() time.Duration { return st.timeShift }
+
+// ScenarioCursor - текущая позиция в сценарии. Безопасен для общего использования
+// соединениями (CursorGlobal); nil-курсор означает работу без сценария
+type ScenarioCursor struct {
+	mu   sync.Mutex
+	sc   *Scenario
+	step int // индекс текущего шага
+	used int // сколько запросов текущий шаг уже обслужил
+	done bool
+}
+
+// NewCursor создаёт курсор в начале сценария
+func (sc *Scenario) NewCursor() *ScenarioCursor {
+	return &ScenarioCursor{sc: sc}
+}
+
+// Next возвращает шаг для запроса и его индекс, если запрос совпал с текущим шагом,
+// и сдвигает курсор. nil - запрос обрабатывается обычно
+func (c *ScenarioCursor) Next(cmd byte, addr byte) (*Step, int) {
+	if c == nil {
+		return nil, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return nil, 0
+	}
+	idx := c.step
+	st := c.sc.Steps[idx]
+	if !st.Matches(cmd, addr) {
+		return nil, 0
+	}
+	c.used++
+	if c.used >= st.Repeat {
+		c.used = 0
+		c.step++
+		if c.step == len(c.sc.Steps) {
+			if c.sc.Loop {
+				c.step = c.sc.LoopFrom
+			} else {
+				c.done = true
+			}
+		}
+	}
+	return st, idx
+}
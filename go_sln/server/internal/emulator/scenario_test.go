@@ -0,0 +1,150 @@
+package emulator
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustScenario(t *testing.T, js string) *Scenario {
+	t.Helper()
+	sc, err := ParseScenario([]byte(js))
+	if err != nil {
+		t.Fatalf("ParseScenario: %v", err)
+	}
+	return sc
+}
+
+func TestParseScenarioDefaults(t *testing.T) {
+	sc := mustScenario(t, `{"steps": [
+		{"cmd": 1},
+		{"action": "error", "time_offset": "-90s"}
+	]}`)
+	if sc.Cursor != CursorConnection || sc.Loop || sc.LoopFrom != 0 {
+		t.Errorf("scenario defaults: cursor %q loop %v loop_from %d", sc.Cursor, sc.Loop, sc.LoopFrom)
+	}
+	st := sc.Steps[0]
+	if st.Action != ActionRespond || st.Repeat != 1 || st.Fault != "" || st.TimeShift() != 0 {
+		t.Errorf("step 0 defaults: %+v", *st)
+	}
+	st = sc.Steps[1]
+	if st.Action != ActionError || st.ErrorCode != 0x04 || st.TimeShift() != -90*time.Second {
+		t.Errorf("step 1: action %q error_code %d shift %s", st.Action, st.ErrorCode, st.TimeShift())
+	}
+	if !st.Matches(0x05, 0x10) {
+		t.Errorf("step without cmd and addr must match any request")
+	}
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	tests := []struct {
+		name, js, want string
+	}{
+		{"bad json", `{"steps": [`, "unexpected end"},
+		{"no steps", `{"steps": []}`, "no steps"},
+		{"unknown cursor", `{"cursor": "thread", "steps": [{}]}`, "unknown cursor"},
+		{"loop_from out of range", `{"loop": true, "loop_from": 1, "steps": [{}]}`, "loop_from"},
+		{"unknown action", `{"steps": [{"action": "reboot"}]}`, "step 0: unknown action"},
+		{"unknown fault", `{"steps": [{}, {"fault": "noise"}]}`, "step 1: unknown fault"},
+		{"error code", `{"steps": [{"action": "error", "error_code": 256}]}`, "error_code"},
+		{"negative repeat", `{"steps": [{"repeat": -1}]}`, "must not be negative"},
+		{"negative delay", `{"steps": [{"delay_ms": -5}]}`, "must not be negative"},
+		{"cmd range", `{"steps": [{"cmd": 300}]}`, "range 0..255"},
+		{"addr range", `{"steps": [{"addr": -1}]}`, "range 0..255"},
+		{"time offset", `{"steps": [{"time_offset": "2 hours"}]}`, "time_offset"},
+	}
+	for _, tt := range tests {
+		_, err := ParseScenario([]byte(tt.js))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+type scenarioReq struct {
+	cmd, addr byte
+	want      int // индекс шага; -1 - запрос обрабатывается обычно
+}
+
+func play(t *testing.T, c *ScenarioCursor, reqs []scenarioReq) {
+	t.Helper()
+	for i, r := range reqs {
+		st, idx := c.Next(r.cmd, r.addr)
+		got := -1
+		if st != nil {
+			got = idx
+		}
+		if got != r.want {
+			t.Fatalf("request %d (cmd 0x%02X addr 0x%02X): step %d, want %d", i, r.cmd, r.addr, got, r.want)
+		}
+	}
+}
+
+// Курсор сдвигается только совпавшими запросами, шаг обслуживает repeat запросов,
+// без loop сценарий заканчивается
+func TestScenarioCursor(t *testing.T) {
+	sc := mustScenario(t, `{"steps": [
+		{"cmd": 1, "repeat": 2},
+		{"cmd": 4, "addr": 1},
+		{"cmd": 1, "action": "silent"}
+	]}`)
+	play(t, sc.NewCursor(), []scenarioReq{
+		{1, 1, 0},
+		{4, 1, -1}, // не текущий шаг - курсор стоит
+		{1, 1, 0},
+		{4, 2, -1}, // другой адрес
+		{4, 1, 1},
+		{1, 1, 2},
+		{1, 1, -1}, // сценарий закончился
+		{4, 1, -1},
+	})
+}
+
+func TestScenarioLoop(t *testing.T) {
+	sc := mustScenario(t, `{"loop": true, "loop_from": 1, "steps": [
+		{"cmd": 3},
+		{"cmd": 1},
+		{"cmd": 1, "repeat": 2, "fault": "badcrc"}
+	]}`)
+	play(t, sc.NewCursor(), []scenarioReq{
+		{3, 1, 0},
+		{1, 1, 1},
+		{1, 1, 2},
+		{1, 1, 2},
+		{3, 1, -1}, // шаг 0 вне цикла
+		{1, 1, 1},
+		{1, 1, 2},
+	})
+}
+
+// Курсоры одного сценария независимы (cursor: connection)
+func TestScenarioCursorsIndependent(t *testing.T) {
+	sc := mustScenario(t, `{"steps": [{"cmd": 1}, {"cmd": 2}]}`)
+	a, b := sc.NewCursor(), sc.NewCursor()
+	play(t, a, []scenarioReq{{1, 1, 0}})
+	play(t, b, []scenarioReq{{2, 1, -1}, {1, 1, 0}})
+	play(t, a, []scenarioReq{{2, 1, 1}})
+}
+
+func TestNilCursor(t *testing.T) {
+	var c *ScenarioCursor
+	if st, _ := c.Next(1, 1); st != nil {
+		t.Fatalf("nil cursor returned step %+v", *st)
+	}
+}
+
+// Пример сценария из репозитория загружается без ошибок
+func TestLoadShippedScenario(t *testing.T) {
+	const path = "../../../scenarios/clock-faults.json"
+	if _, err := os.Stat(path); err != nil {
+		t.Skipf("shipped scenario: %v", err)
+	}
+	sc, err := LoadScenario(path)
+	if err != nil {
+		t.Fatalf("LoadScenario: %v", err)
+	}
+	if sc.Cursor != CursorGlobal || !sc.Loop || len(sc.Steps) == 0 {
+		t.Errorf("clock-faults: cursor %q loop %v, %d step(s)", sc.Cursor, sc.Loop, len(sc.Steps))
+	}
+}
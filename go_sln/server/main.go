@@ -3,8 +3,10 @@ package main
 import (
 	"os"
 	"os/signal"
+	"sln/ft12"
 	"sln/internal/config"
 	"sln/internal/emu"
+	"sln/internal/emulator"
 	"sln/internal/logging"
 	"syscall"
 )
@@ -16,11 +18,73 @@ func main() {
 
 	logger := logging.New(cfg.LogFile)
 
-	logger.Printf("starting ttp20 emulator (host=%s port=%d crc=%s adapter=%d)",
-		cfg.Host, cfg.Port, cfg.CRCMode, cfg.AdapterAddr)
+	if _, err := ft12.ParseDialect(cfg.Dialect); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	for _, def := range cfg.CRCDefs {
+		if err := ft12.RegisterCRCDef(def); err != nil {
+			logger.Fatalf("invalid config: %v", err)
+		}
+	}
+	if _, err := ft12.LookupChecksum(cfg.CRCMode); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	if _, err := ft12.LookupTimeCodec(cfg.TimeFormat); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
+	if cfg.AdapterAddr < 0 || cfg.AdapterAddr > 0xFF {
+		logger.Fatalf("invalid config: adapter address %d out of range 0..255", cfg.AdapterAddr)
+	}
+	if cfg.Broadcast < emulator.NoBroadcast || cfg.Broadcast > 0xFF || cfg.Broadcast == cfg.AdapterAddr {
+		logger.Fatalf("invalid config: broadcast address %d must be -1 or 0..255 and differ from -adapter", cfg.Broadcast)
+	}
+	if cfg.ReplaySpeed < 0 {
+		logger.Fatalf("invalid config: replayspeed %g must not be negative", cfg.ReplaySpeed)
+	}
+	switch cfg.Transport {
+	case emu.TransportTCP, emu.TransportUDP:
+	case emu.TransportUnix:
+		if cfg.Socket == "" {
+			logger.Fatalf("invalid config: unix transport needs -socket")
+		}
+	case emu.TransportPTY:
+		if _, err := emu.SerialMode(cfg); err != nil {
+			logger.Fatalf("invalid config: %v", err)
+		}
+	default:
+		logger.Fatalf("invalid config: unknown transport %q (want tcp | udp | unix | pty)", cfg.Transport)
+	}
+	if cfg.Upstream != "" {
+		if _, err := emu.UpstreamTransport(cfg); err != nil {
+			logger.Fatalf("invalid config: upstream: %v", err)
+		}
+		if cfg.Replay != "" || cfg.Scenario != "" || cfg.Profile != "" {
+			logger.Fatalf("invalid config: -upstream cannot be combined with -replay, -scenario or -profile")
+		}
+	}
+	if cfg.Learn != "" && cfg.Upstream == "" {
+		logger.Fatalf("invalid config: -learn needs -upstream (learning works through the proxy)")
+	}
+	if cfg.Profile != "" && cfg.Replay != "" {
+		logger.Fatalf("invalid config: -profile cannot be combined with -replay")
+	}
+	switch cfg.FaultDir {
+	case emu.FaultResponses, emu.FaultRequests, emu.FaultBoth:
+	default:
+		logger.Fatalf("invalid config: unknown faultdir %q (want responses | requests | both)", cfg.FaultDir)
+	}
+	if cfg.TLS && cfg.Transport != emu.TransportTCP && cfg.Transport != emu.TransportUnix {
+		logger.Fatalf("invalid config: -tls needs tcp or unix transport, not %s", cfg.Transport)
+	}
 
-	// Создаём сервер-эмулятор
-	srv := emu.NewServer(cfg, logger)
+	logger.Printf("starting ttp20 emulator (transport=%s host=%s port=%d crc=%s dialect=%s adapter=%d broadcast=%d timefmt=%s)",
+		cfg.Transport, cfg.Host, cfg.Port, cfg.CRCMode, cfg.Dialect, cfg.AdapterAddr, cfg.Broadcast, cfg.TimeFormat)
+
+	// Создаём сервер-эмулятор (загружает карту регистров, сценарий и запись для воспроизведения)
+	srv, err := emu.NewServer(cfg, logger)
+	if err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
 
 	// Запускаем сервер в отдельной горутине и отслеживаем ошибку
 	errCh := make(chan error, 1)